@@ -0,0 +1,335 @@
+// Package client is a typed Go client for the todo HTTP API.
+//
+//	c := client.New("http://localhost:4000", client.WithCredentials("ada", "secret"))
+//	todo, err := c.GetTodo(ctx, 42)
+//
+// Requests that fail with a transient error are retried, and when credentials are configured the client
+// logs in on its first request and again whenever its token is about to expire or is rejected.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todolist/helper"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a request failing with a transient error is retried
+	DefaultMaxRetries = 2
+	// tokenRefreshMargin is how long before its expiry a token is replaced
+	tokenRefreshMargin = time.Minute
+	// retryBaseDelay is the delay before the first retry; it doubles with every attempt
+	retryBaseDelay = 200 * time.Millisecond
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+)
+
+// APIError is returned when the API responds with a status outside the 2xx range.
+// Message and Details are decoded from the helper.ResponseData body of the response.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Details    interface{}
+}
+
+func (e *APIError) Error() string {
+	if e.Details != nil && e.Details != "" {
+		return fmt.Sprintf("%s (status %d): %v", e.Message, e.StatusCode, e.Details)
+	}
+	return fmt.Sprintf("%s (status %d)", e.Message, e.StatusCode)
+}
+
+// Is lets callers match API errors with errors.Is(err, ErrUnauthorized) and errors.Is(err, ErrNotFound)
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	default:
+		return false
+	}
+}
+
+// Client calls the todo API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+
+	mu       sync.Mutex
+	token    string
+	username string
+	password string
+	onToken  func(token string)
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, e.g. to configure timeouts or TLS
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken sets the JWT sent with requests, such as one saved from an earlier Login
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithCredentials lets the client log in by itself and obtain a new token when the current one expires
+func WithCredentials(username, password string) Option {
+	return func(c *Client) { c.username, c.password = username, password }
+}
+
+// WithMaxRetries sets how many times a request failing with a transient error is retried
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// WithTokenHook registers a function called with every new token obtained by the client, so it can be persisted
+func WithTokenHook(hook func(token string)) Option {
+	return func(c *Client) { c.onToken = hook }
+}
+
+// New returns a client for the API served at baseURL, such as "http://localhost:4000"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/") + "/api/v1",
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: DefaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the token currently sent with requests
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Login exchanges a username and password for a token, which is then used by every request of the client
+func (c *Client) Login(ctx context.Context, username, password string) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	body := map[string]string{"username": username, "password": password}
+	if err := c.taskRequest(ctx, http.MethodPost, "/login", body, &result, false); err != nil {
+		return "", err
+	}
+
+	c.setToken(result.Token)
+	return result.Token, nil
+}
+
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	hook := c.onToken
+	c.mu.Unlock()
+
+	if hook != nil {
+		hook(token)
+	}
+}
+
+// authenticate returns the token to send, logging in first when credentials are configured and the
+// current token is missing, expiring soon or was just rejected
+func (c *Client) authenticate(ctx context.Context, rejected bool) (string, error) {
+	c.mu.Lock()
+	token, username, password := c.token, c.username, c.password
+	c.mu.Unlock()
+
+	if username == "" || (!rejected && token != "" && !tokenExpiresSoon(token)) {
+		return token, nil
+	}
+	return c.Login(ctx, username, password)
+}
+
+// tokenExpiresSoon reads the exp claim of a JWT without verifying it, which is left to the server
+func tokenExpiresSoon(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return false
+	}
+	return time.Until(time.Unix(claims.Exp, 0)) < tokenRefreshMargin
+}
+
+// do sends an authenticated request and decodes the response body into out, if not nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.request(ctx, method, path, body, out, true)
+}
+
+// task sends an authenticated request and decodes the task field of the helper.ResponseData body into out
+func (c *Client) task(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.taskRequest(ctx, method, path, body, out, true)
+}
+
+func (c *Client) taskRequest(ctx context.Context, method, path string, body, out interface{}, authenticated bool) error {
+	var response struct {
+		Task json.RawMessage `json:"task"`
+	}
+	if err := c.request(ctx, method, path, body, &response, authenticated); err != nil {
+		return err
+	}
+	if out == nil || len(response.Task) == 0 || string(response.Task) == "null" {
+		return nil
+	}
+	return json.Unmarshal(response.Task, out)
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body, out interface{}, authenticated bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	rejected := false
+	for attempt := 0; ; attempt++ {
+		var token string
+		if authenticated {
+			var err error
+			if token, err = c.authenticate(ctx, rejected); err != nil {
+				return err
+			}
+		}
+
+		resp, err := c.send(ctx, method, path, payload, token)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+
+		if err == nil {
+			err = decodeError(resp)
+			resp.Body.Close()
+
+			// A rejected token is replaced once, when the client can log in by itself
+			if errors.Is(err, ErrUnauthorized) && authenticated && !rejected && c.hasCredentials() {
+				rejected = true
+				continue
+			}
+		}
+
+		if attempt >= c.maxRetries || !retryable(method, resp, err) {
+			return err
+		}
+		if err := sleep(ctx, retryDelay(attempt, resp)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, token string) (*http.Response, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) hasCredentials() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username != ""
+}
+
+// decodeError builds an APIError from a response, falling back to the raw body when it is not a helper.ResponseData
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var response helper.ResponseData
+	if err := json.Unmarshal(data, &response); err != nil || response.Message == "" {
+		response.Message = strings.TrimSpace(string(data))
+		if response.Message == "" {
+			response.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: response.Message, Details: response.Error}
+}
+
+// retryable reports whether a failed request may be sent again: only idempotent requests are retried
+// after a network error or a 429, 502, 503 or 504 response, so a create is never applied twice
+func retryable(method string, resp *http.Response, err error) bool {
+	if method == http.MethodPost {
+		return false
+	}
+	if resp == nil {
+		return err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryDelay honors a Retry-After header given in seconds, and otherwise backs off exponentially
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return retryBaseDelay << attempt
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/helper"
+	"todolist/models"
+)
+
+func respond(w http.ResponseWriter, status int, message string, task, errorDetails interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(helper.ResponseData{Success: status < 300, Message: message, Task: task, Error: errorDetails})
+}
+
+func fakeToken(exp time.Time) string {
+	payload, _ := json.Marshal(map[string]int64{"exp": exp.Unix()})
+	return "header." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestLoginAndTokenRefresh(t *testing.T) {
+	var logins atomic.Int32
+	validToken := fakeToken(time.Now().Add(time.Hour))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/login":
+			logins.Add(1)
+			respond(w, http.StatusOK, "Login successful", map[string]string{"token": validToken}, nil)
+		case "/api/v1/todo/1":
+			if r.Header.Get("Authorization") != "Bearer "+validToken {
+				respond(w, http.StatusUnauthorized, "Unauthorized", nil, "token is expired")
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"todo": models.TodoList{ID: 1, Title: "Buy milk"}})
+		}
+	}))
+	defer server.Close()
+
+	var saved string
+	c := New(server.URL, WithToken("stale"), WithCredentials("ada", "secret"), WithTokenHook(func(token string) { saved = token }))
+
+	todo, err := c.GetTodo(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy milk", todo.Title)
+	assert.Equal(t, int32(1), logins.Load(), "a rejected token is replaced by logging in again")
+	assert.Equal(t, validToken, saved)
+
+	_, err = c.GetTodo(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), logins.Load())
+
+	// Without credentials the rejection is returned to the caller
+	_, err = New(server.URL, WithToken("stale")).GetTodo(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Unauthorized", apiErr.Message)
+	assert.Equal(t, "token is expired", apiErr.Details)
+}
+
+func TestTokenExpiresSoon(t *testing.T) {
+	assert.True(t, tokenExpiresSoon(fakeToken(time.Now().Add(30*time.Second))))
+	assert.False(t, tokenExpiresSoon(fakeToken(time.Now().Add(time.Hour))))
+	assert.False(t, tokenExpiresSoon("not-a-jwt"))
+}
+
+func TestRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			respond(w, http.StatusServiceUnavailable, "Service unavailable", nil, nil)
+			return
+		}
+		if r.Method == http.MethodPost {
+			respond(w, http.StatusServiceUnavailable, "Service unavailable", nil, nil)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"todo": models.TodoList{ID: 1}})
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithToken("token"))
+	_, err := c.GetTodo(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// Creates are never retried, so they cannot be applied twice
+	requests.Store(1)
+	_, err = c.CreateTodo(context.Background(), &models.TodoList{Title: "Buy milk"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestGetTodoNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"todo": nil})
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, WithToken("token")).GetTodo(context.Background(), 7)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestListTodos(t *testing.T) {
+	const total = 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		result := TodoPage{CurrentPage: page, TotalPages: (total + limit - 1) / limit, TotalTasks: total, Todos: []models.TodoList{}}
+		for id := (page-1)*limit + 1; id <= min(page*limit, total); id++ {
+			result.Todos = append(result.Todos, models.TodoList{ID: id, Title: fmt.Sprintf("Todo %d", id)})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	it := New(server.URL, WithToken("token")).ListTodos(context.Background(), 2)
+	var ids []int
+	for it.Next() {
+		ids = append(ids, it.Todo().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids)
+}
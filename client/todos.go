@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"todolist/models"
+)
+
+// DefaultPageSize is the number of todos fetched per request by the iterator returned from ListTodos
+const DefaultPageSize = 50
+
+// User is a registered user as returned by Register
+type User struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// TodoPage is a single page of todos as returned by GET /todos
+type TodoPage struct {
+	Todos       []models.TodoList `json:"tasks"`
+	CurrentPage int               `json:"current_page"`
+	TotalPages  int               `json:"total_pages"`
+	TotalTasks  int               `json:"total_tasks"`
+}
+
+// Register creates a user account
+func (c *Client) Register(ctx context.Context, username, password string) (*User, error) {
+	var user User
+	body := map[string]string{"username": username, "password": password}
+	err := c.taskRequest(ctx, http.MethodPost, "/register", body, &user, false)
+	return &user, err
+}
+
+// GetTodosPage retrieves a single page of todos; pages start at 1
+func (c *Client) GetTodosPage(ctx context.Context, page, limit int) (*TodoPage, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+
+	var result TodoPage
+	err := c.do(ctx, http.MethodGet, "/todos?"+query.Encode(), nil, &result)
+	return &result, err
+}
+
+// GetTodo retrieves a todo by ID, returning an error matching ErrNotFound when it does not exist
+func (c *Client) GetTodo(ctx context.Context, id int) (*models.TodoList, error) {
+	var result struct {
+		Todo *models.TodoList `json:"todo"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/todo/"+strconv.Itoa(id), nil, &result); err != nil {
+		return nil, err
+	}
+	if result.Todo == nil {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Message: "Todo not found"}
+	}
+	return result.Todo, nil
+}
+
+// CreateTodo creates a todo and returns it with its ID set
+func (c *Client) CreateTodo(ctx context.Context, todo *models.TodoList) (*models.TodoList, error) {
+	var created models.TodoList
+	err := c.task(ctx, http.MethodPost, "/todo", todo, &created)
+	return &created, err
+}
+
+// UpdateTodo replaces the fields of the todo with the given ID
+func (c *Client) UpdateTodo(ctx context.Context, id int, todo *models.TodoList) (*models.TodoList, error) {
+	var updated models.TodoList
+	err := c.task(ctx, http.MethodPut, "/todo/"+strconv.Itoa(id), todo, &updated)
+	return &updated, err
+}
+
+// DeleteTodo moves the todo with the given ID to the trash
+func (c *Client) DeleteTodo(ctx context.Context, id int) error {
+	return c.task(ctx, http.MethodDelete, "/todo/"+strconv.Itoa(id), nil, nil)
+}
+
+// ListTodos returns an iterator over every todo, fetching pageSize todos per request as it advances.
+// A pageSize below 1 uses DefaultPageSize.
+//
+//	it := c.ListTodos(ctx, 100)
+//	for it.Next() {
+//		fmt.Println(it.Todo().Title)
+//	}
+//	if err := it.Err(); err != nil { ... }
+func (c *Client) ListTodos(ctx context.Context, pageSize int) *TodoIterator {
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	return &TodoIterator{client: c, ctx: ctx, pageSize: pageSize}
+}
+
+// TodoIterator walks through the pages of todos one todo at a time
+type TodoIterator struct {
+	client   *Client
+	ctx      context.Context
+	pageSize int
+
+	page  *TodoPage
+	index int
+	done  bool
+	err   error
+}
+
+// Next advances to the next todo, fetching the following page when needed.
+// It returns false when there are no more todos or an error occurred.
+func (it *TodoIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if it.page != nil && it.index+1 < len(it.page.Todos) {
+		it.index++
+		return true
+	}
+	if it.page != nil && it.page.CurrentPage >= it.page.TotalPages {
+		it.done = true
+		return false
+	}
+
+	next := 1
+	if it.page != nil {
+		next = it.page.CurrentPage + 1
+	}
+	page, err := it.client.GetTodosPage(it.ctx, next, it.pageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if len(page.Todos) == 0 {
+		it.done = true
+		return false
+	}
+
+	it.page, it.index = page, 0
+	return true
+}
+
+// Todo returns the current todo; it is only valid after Next returned true
+func (it *TodoIterator) Todo() *models.TodoList {
+	return &it.page.Todos[it.index]
+}
+
+// Page returns the page holding the current todo, e.g. to read the total number of todos
+func (it *TodoIterator) Page() *TodoPage {
+	return it.page
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *TodoIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,478 @@
+// Command todo is a command-line client for the todo API.
+//
+// Usage:
+//
+//	todo login [-server URL] [-username NAME]
+//	todo logout
+//	todo add [-desc TEXT] [-due YYYY-MM-DD] [-priority A-Z] TITLE...
+//	todo list [-status STATUS] [-search TEXT] [-due-before DATE] [-due-after DATE] [-page-size N] [-max N] [-o table|json]
+//	todo show [-o table|json] ID
+//	todo edit [-title TEXT] [-desc TEXT] [-due YYYY-MM-DD|none] [-priority A-Z|none] [-status STATUS] ID
+//	todo done ID...
+//	todo rm ID...
+//
+// The token obtained by login is stored in the config file (see configPath) and sent with every request.
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"todolist/client"
+	"todolist/models"
+)
+
+var errNotLoggedIn = errors.New("not logged in, run `todo login` first")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "todo: failed to load config:", err)
+		os.Exit(1)
+	}
+
+	commands := map[string]func(*config, []string) error{
+		"login":  runLogin,
+		"logout": runLogout,
+		"add":    runAdd,
+		"list":   runList,
+		"ls":     runList,
+		"show":   runShow,
+		"edit":   runEdit,
+		"done":   runDone,
+		"rm":     runRemove,
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+			fmt.Fprintf(os.Stderr, "todo: unknown command %q\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command(cfg, os.Args[2:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+		case errors.Is(err, client.ErrUnauthorized):
+			fmt.Fprintln(os.Stderr, "todo:", err)
+			fmt.Fprintln(os.Stderr, "todo: your session may have expired, run `todo login`")
+		default:
+			fmt.Fprintln(os.Stderr, "todo:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// newClient returns an API client using the stored token, failing when there is none
+func newClient(cfg *config) (*client.Client, error) {
+	if cfg.Token == "" {
+		return nil, errNotLoggedIn
+	}
+	return client.New(cfg.Server, client.WithToken(cfg.Token)), nil
+}
+
+// parseID parses a todo ID given on the command line
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid todo ID %q", arg)
+	}
+	return id, nil
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: todo <command> [flags] [arguments]
+
+Commands:
+  login    log in and store the API token
+  logout   forget the stored API token
+  add      create a todo
+  list     list todos, following every page
+  show     show a single todo
+  edit     change fields of a todo
+  done     mark todos as completed
+  rm       move todos to the trash
+
+Run "todo <command> -h" for the flags of a command.
+`)
+}
+
+func runLogin(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := flags.String("server", cfg.Server, "API address")
+	username := flags.String("username", cfg.Username, "username")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		value, err := prompt("Username: ", false)
+		if err != nil {
+			return err
+		}
+		*username = value
+	}
+	password := os.Getenv("TODO_PASSWORD")
+	if password == "" {
+		value, err := prompt("Password: ", true)
+		if err != nil {
+			return err
+		}
+		password = value
+	}
+
+	cfg.Server, cfg.Username, cfg.Token = *server, *username, ""
+	token, err := client.New(cfg.Server).Login(context.Background(), *username, password)
+	if err != nil {
+		return err
+	}
+
+	cfg.Token = token
+	if err := cfg.save(); err != nil {
+		return fmt.Errorf("logged in but failed to save the token: %w", err)
+	}
+	fmt.Printf("Logged in to %s as %s\n", cfg.Server, cfg.Username)
+	return nil
+}
+
+func runLogout(cfg *config, args []string) error {
+	cfg.Token = ""
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Println("Logged out")
+	return nil
+}
+
+func runAdd(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	description := flags.String("desc", "", "description (defaults to the title)")
+	due := flags.String("due", "", "due date as YYYY-MM-DD")
+	priority := flags.String("priority", "", "priority from A (highest) to Z")
+	output := flags.String("o", outputTable, "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	todo := &models.TodoList{
+		Title:       strings.Join(flags.Args(), " "),
+		Description: *description,
+		Status:      "pending",
+		Priority:    strings.ToUpper(*priority),
+	}
+	if todo.Title == "" {
+		return errors.New("a title is required")
+	}
+	if todo.Description == "" {
+		todo.Description = todo.Title
+	}
+	if err := setDueDate(todo, *due); err != nil {
+		return err
+	}
+
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	created, err := api.CreateTodo(context.Background(), todo)
+	if err != nil {
+		return err
+	}
+	return printTodoDetails(os.Stdout, created, *output)
+}
+
+func runList(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	status := flags.String("status", "", "only show todos with this status (pending or completed)")
+	search := flags.String("search", "", "only show todos whose title contains this text")
+	dueBefore := flags.String("due-before", "", "only show todos due on or before this date")
+	dueAfter := flags.String("due-after", "", "only show todos due on or after this date")
+	pageSize := flags.Int("page-size", 50, "number of todos fetched per request")
+	max := flags.Int("max", 0, "stop after this many todos (0 for all)")
+	output := flags.String("o", outputTable, "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	match, err := todoFilter(*status, *search, *dueBefore, *dueAfter)
+	if err != nil {
+		return err
+	}
+	printer, err := newTodoPrinter(os.Stdout, *output)
+	if err != nil {
+		return err
+	}
+
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	it := api.ListTodos(context.Background(), *pageSize)
+	var page *client.TodoPage
+	for it.Next() {
+		// Show each page as soon as it arrives instead of waiting for the whole list
+		if page != nil && page != it.Page() {
+			if err := printer.flush(); err != nil {
+				return err
+			}
+		}
+		page = it.Page()
+
+		if !match(it.Todo()) {
+			continue
+		}
+		if err := printer.print(it.Todo()); err != nil {
+			return err
+		}
+		if *max > 0 && printer.count >= *max {
+			break
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return printer.close()
+}
+
+// todoFilter returns a predicate for the list filters; the API does not filter pages, so it runs on each todo
+func todoFilter(status, search, dueBefore, dueAfter string) (func(*models.TodoList) bool, error) {
+	if dueBefore != "" {
+		if _, err := time.Parse("2006-01-02", dueBefore); err != nil {
+			return nil, fmt.Errorf("invalid -due-before %q, expected YYYY-MM-DD", dueBefore)
+		}
+	}
+	if dueAfter != "" {
+		if _, err := time.Parse("2006-01-02", dueAfter); err != nil {
+			return nil, fmt.Errorf("invalid -due-after %q, expected YYYY-MM-DD", dueAfter)
+		}
+	}
+	search = strings.ToLower(search)
+
+	return func(todo *models.TodoList) bool {
+		if status != "" && todo.Status != status {
+			return false
+		}
+		if search != "" && !strings.Contains(strings.ToLower(todo.Title), search) {
+			return false
+		}
+		// Due dates are calendar dates in whatever offset the API sent them; YYYY-MM-DD strings compare in date order
+		due := todo.DueDate.Time.Format("2006-01-02")
+		if dueBefore != "" && (!todo.DueDate.Valid || due > dueBefore) {
+			return false
+		}
+		if dueAfter != "" && (!todo.DueDate.Valid || due < dueAfter) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func runShow(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	output := flags.String("o", outputTable, "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("exactly one todo ID is required")
+	}
+
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	todo, err := api.GetTodo(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return printTodoDetails(os.Stdout, todo, *output)
+}
+
+func runEdit(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	title := flags.String("title", "", "new title")
+	description := flags.String("desc", "", "new description")
+	due := flags.String("due", "", `new due date as YYYY-MM-DD, or "none"`)
+	priority := flags.String("priority", "", `new priority from A to Z, or "none"`)
+	status := flags.String("status", "", "new status (pending or completed)")
+	output := flags.String("o", outputTable, "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("exactly one todo ID is required")
+	}
+
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+	todo, err := api.GetTodo(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	// Only the flags given on the command line change the todo
+	changed := false
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			todo.Title, changed = *title, true
+		case "desc":
+			todo.Description, changed = *description, true
+		case "due":
+			flagErr, changed = setDueDate(todo, *due), true
+		case "priority":
+			todo.Priority, changed = strings.ToUpper(*priority), true
+			if todo.Priority == "NONE" {
+				todo.Priority = ""
+			}
+		case "status":
+			todo.Status, changed = *status, true
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+	if !changed {
+		return errors.New("nothing to change, pass at least one of -title, -desc, -due, -priority or -status")
+	}
+
+	updated, err := api.UpdateTodo(context.Background(), id, todo)
+	if err != nil {
+		return err
+	}
+	return printTodoDetails(os.Stdout, updated, *output)
+}
+
+func runDone(cfg *config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one todo ID is required")
+	}
+
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		todo, err := api.GetTodo(context.Background(), id)
+		if err != nil {
+			return err
+		}
+		if todo.Status == "completed" {
+			fmt.Printf("Todo %d is already completed\n", id)
+			continue
+		}
+
+		todo.Status = "completed"
+		if _, err := api.UpdateTodo(context.Background(), id, todo); err != nil {
+			return fmt.Errorf("failed to complete todo %d: %w", id, err)
+		}
+		fmt.Printf("Completed todo %d\n", id)
+	}
+	return nil
+}
+
+func runRemove(cfg *config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("at least one todo ID is required")
+	}
+
+	api, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		if err := api.DeleteTodo(context.Background(), id); err != nil {
+			return fmt.Errorf("failed to remove todo %d: %w", id, err)
+		}
+		fmt.Printf("Moved todo %d to the trash\n", id)
+	}
+	return nil
+}
+
+// setDueDate sets the due date of todo from a YYYY-MM-DD value; "none" clears it and "" leaves it unset
+func setDueDate(todo *models.TodoList, value string) error {
+	switch value {
+	case "":
+		return nil
+	case "none":
+		todo.DueDate = sql.NullTime{}
+		return nil
+	}
+
+	due, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", value)
+	}
+	todo.DueDate = sql.NullTime{Time: due, Valid: true}
+	return nil
+}
+
+// prompt reads a line from the terminal; with secret, echo is turned off while typing when stdin is a terminal
+func prompt(label string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+
+	if secret && isTerminal(os.Stdin) {
+		if err := stty("-echo"); err == nil {
+			defer func() {
+				stty("echo")
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func TestConfigSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo", "config.json")
+	t.Setenv("TODO_CONFIG", path)
+	t.Setenv("TODO_SERVER", "")
+
+	cfg := &config{Server: "http://todo.example", Username: "ada", Token: "secret"}
+	require.NoError(t, cfg.save())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, cfg, loaded)
+}
+
+func TestTodoFilter(t *testing.T) {
+	due := func(date string) sql.NullTime {
+		d, _ := time.Parse("2006-01-02", date)
+		return sql.NullTime{Time: d, Valid: true}
+	}
+	todos := []models.TodoList{
+		{ID: 1, Title: "Buy milk", Status: "pending", DueDate: due("2024-05-01")},
+		{ID: 2, Title: "Pay rent", Status: "completed", DueDate: due("2024-05-03")},
+		{ID: 3, Title: "Read a book", Status: "pending"},
+	}
+
+	matching := func(match func(*models.TodoList) bool) []int {
+		var ids []int
+		for i := range todos {
+			if match(&todos[i]) {
+				ids = append(ids, todos[i].ID)
+			}
+		}
+		return ids
+	}
+
+	match, err := todoFilter("pending", "", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, matching(match))
+
+	match, err = todoFilter("", "RENT", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, matching(match))
+
+	match, err = todoFilter("", "", "2024-05-02", "2024-05-01")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, matching(match))
+
+	_, err = todoFilter("", "", "tomorrow", "")
+	assert.Error(t, err)
+}
+
+func TestTodoFilterComparesCalendarDates(t *testing.T) {
+	// Midnight on 1 May in +02:00 is still 30 April in UTC
+	todo := models.TodoList{ID: 1, Status: "pending", DueDate: sql.NullTime{
+		Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), Valid: true}}
+
+	match, err := todoFilter("", "", "2024-05-01", "2024-05-01")
+	require.NoError(t, err)
+	assert.True(t, match(&todo))
+
+	match, err = todoFilter("", "", "2024-04-30", "")
+	require.NoError(t, err)
+	assert.False(t, match(&todo))
+}
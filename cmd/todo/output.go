@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"todolist/models"
+)
+
+// Output formats selected with -o
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// todoJSON is the JSON form of a todo printed by the CLI, with plain dates instead of nullable wrappers
+type todoJSON struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Status       string `json:"status"`
+	Priority     string `json:"priority,omitempty"`
+	DueDate      string `json:"due_date,omitempty"`
+	ParentID     int64  `json:"parent_id,omitempty"`
+	AutoComplete bool   `json:"auto_complete"`
+	Recurrence   string `json:"recurrence,omitempty"`
+	Version      int    `json:"version"`
+}
+
+func toJSON(todo *models.TodoList) todoJSON {
+	out := todoJSON{
+		ID:           todo.ID,
+		Title:        todo.Title,
+		Description:  todo.Description,
+		Status:       todo.Status,
+		Priority:     todo.Priority,
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   todo.Recurrence,
+		Version:      todo.Version,
+	}
+	if todo.DueDate.Valid {
+		out.DueDate = todo.DueDate.Time.Format("2006-01-02")
+	}
+	if todo.ParentID.Valid {
+		out.ParentID = todo.ParentID.Int64
+	}
+	return out
+}
+
+// todoPrinter prints todos one at a time so long lists are shown as their pages arrive
+type todoPrinter struct {
+	w      io.Writer
+	format string
+	table  *tabwriter.Writer
+	count  int
+}
+
+func newTodoPrinter(w io.Writer, format string) (*todoPrinter, error) {
+	if format != outputTable && format != outputJSON {
+		return nil, fmt.Errorf("unknown output format %q, expected table or json", format)
+	}
+	return &todoPrinter{w: w, format: format}, nil
+}
+
+func (p *todoPrinter) print(todo *models.TodoList) error {
+	defer func() { p.count++ }()
+
+	if p.format == outputJSON {
+		separator := ",\n  "
+		if p.count == 0 {
+			separator = "[\n  "
+		}
+		data, err := json.Marshal(toJSON(todo))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s%s", separator, data)
+		return err
+	}
+
+	if p.table == nil {
+		p.table = tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(p.table, "ID\tSTATUS\tPRI\tDUE\tTITLE")
+	}
+	due := "-"
+	if todo.DueDate.Valid {
+		due = todo.DueDate.Time.Format("2006-01-02")
+	}
+	priority := todo.Priority
+	if priority == "" {
+		priority = "-"
+	}
+	_, err := fmt.Fprintf(p.table, "%d\t%s\t%s\t%s\t%s\n", todo.ID, todo.Status, priority, due, truncateTitle(todo.Title))
+	return err
+}
+
+// flush writes out what has been buffered so far; the table is realigned after each flush
+func (p *todoPrinter) flush() error {
+	if p.table != nil {
+		return p.table.Flush()
+	}
+	return nil
+}
+
+// close terminates the output, printing an empty list or table header when nothing was printed
+func (p *todoPrinter) close() error {
+	if p.format == outputJSON {
+		if p.count == 0 {
+			_, err := fmt.Fprintln(p.w, "[]")
+			return err
+		}
+		_, err := fmt.Fprintln(p.w, "\n]")
+		return err
+	}
+	if p.count == 0 {
+		_, err := fmt.Fprintln(p.w, "No todos found")
+		return err
+	}
+	return p.flush()
+}
+
+// printTodoDetails prints every field of a single todo
+func printTodoDetails(w io.Writer, todo *models.TodoList, format string) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(toJSON(todo))
+	}
+	if format != outputTable {
+		return fmt.Errorf("unknown output format %q, expected table or json", format)
+	}
+
+	out := toJSON(todo)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"ID", fmt.Sprint(out.ID)},
+		{"Title", out.Title},
+		{"Status", out.Status},
+		{"Priority", out.Priority},
+		{"Due", out.DueDate},
+		{"Recurrence", out.Recurrence},
+		{"Auto-complete", fmt.Sprint(out.AutoComplete)},
+		{"Version", fmt.Sprint(out.Version)},
+	}
+	if out.ParentID != 0 {
+		rows = append(rows, [2]string{"Parent", fmt.Sprint(out.ParentID)})
+	}
+	for _, row := range rows {
+		if row[1] == "" {
+			row[1] = "-"
+		}
+		fmt.Fprintf(table, "%s:\t%s\n", row[0], row[1])
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%s\n", out.Description)
+	return err
+}
+
+func truncateTitle(title string) string {
+	title = strings.ReplaceAll(title, "\n", " ")
+	if runes := []rune(title); len(runes) > 60 {
+		return string(runes[:59]) + "…"
+	}
+	return title
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/godror/godror"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 var DB *sql.DB
@@ -24,16 +28,22 @@ func InitDatabase() (*sql.DB, *redis.Client, error) {
 		DB:       0,
 	})
 
+	// Trace every Redis command as a child of the span of the request issuing it
+	if err := redisotel.InstrumentTracing(RedisClient); err != nil {
+		return nil, nil, err
+	}
+
 	// Test Redis connection
 	status, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
-		log.Fatalln("Redis connection was refused:", err)
+		return nil, nil, fmt.Errorf("redis connection was refused: %w", err)
 	}
-	fmt.Println("Redis status:", status)
+	slog.Info("Connected to Redis", "status", status)
 
 	// Initialize Oracle DB connection
 	dsn := `user="system" password="Ahay1234" connectString="localhost:1521/FREE" timezone="Europe/Berlin"`
-	DB, err = sql.Open("godror", dsn)
+	// Open through otelsql so every query is traced as a child of the span of the request issuing it
+	DB, err = otelsql.Open("godror", dsn, otelsql.WithAttributes(semconv.DBSystemOracle))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -54,7 +64,51 @@ func InitDatabase() (*sql.DB, *redis.Client, error) {
 			title      VARCHAR2(255),
 			description VARCHAR2(255),
 			status     VARCHAR2(50),
-			due_date   DATE
+			due_date   DATE,
+			deleted_at TIMESTAMP,
+			parent_id  INTEGER REFERENCES TODOLIST (id) ON DELETE SET NULL,
+			position   INTEGER DEFAULT 0 NOT NULL,
+			auto_complete NUMBER(1) DEFAULT 0 NOT NULL,
+			recurrence VARCHAR2(255),
+			occurrence INTEGER DEFAULT 1 NOT NULL,
+			previous_id INTEGER,
+			version    INTEGER DEFAULT 1 NOT NULL,
+			sync_seq   INTEGER,
+			priority   VARCHAR2(1),
+			owner_id   INTEGER
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	// Add columns to tables created before they existed
+	for _, column := range []string{
+		`deleted_at TIMESTAMP`,
+		`parent_id INTEGER REFERENCES TODOLIST (id) ON DELETE SET NULL`,
+		`position INTEGER DEFAULT 0 NOT NULL`,
+		`auto_complete NUMBER(1) DEFAULT 0 NOT NULL`,
+		`recurrence VARCHAR2(255)`,
+		`occurrence INTEGER DEFAULT 1 NOT NULL`,
+		`previous_id INTEGER`,
+		`version INTEGER DEFAULT 1 NOT NULL`,
+		`sync_seq INTEGER`,
+		`priority VARCHAR2(1)`,
+		`owner_id INTEGER`,
+	} {
+		_, err = DB.Exec(`ALTER TABLE TODOLIST ADD (` + column + `)`)
+		if err != nil && !isColumnAlreadyExistsError(err) {
+			return nil, nil, err
+		}
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS TODO_CHECKLIST (
+			id       INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
+			todo_id  INTEGER NOT NULL REFERENCES TODOLIST (id) ON DELETE CASCADE,
+			content  VARCHAR2(255) NOT NULL,
+			done     NUMBER(1) DEFAULT 0 NOT NULL,
+			position INTEGER NOT NULL
 		)
 	`)
 	if err != nil && !isTableAlreadyExistsError(err) {
@@ -65,17 +119,204 @@ func InitDatabase() (*sql.DB, *redis.Client, error) {
 		CREATE TABLE IF NOT EXISTS USERS (
 			id       INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
 			username VARCHAR2(50) UNIQUE NOT NULL,
-			password VARCHAR2(70) NOT NULL
+			password VARCHAR2(70) NOT NULL,
+			role     VARCHAR2(20) DEFAULT 'user' NOT NULL
 		)
 	`)
 	if err != nil && !isTableAlreadyExistsError(err) {
 		return nil, nil, err
 	}
 
+	_, err = DB.Exec(`ALTER TABLE USERS ADD (role VARCHAR2(20) DEFAULT 'user' NOT NULL)`)
+	if err != nil && !isColumnAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS TODO_REMINDERS (
+			id              INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
+			todo_id         INTEGER NOT NULL REFERENCES TODOLIST (id) ON DELETE CASCADE,
+			offset_minutes  INTEGER DEFAULT 0 NOT NULL,
+			channel         VARCHAR2(20) NOT NULL,
+			target          VARCHAR2(255) NOT NULL,
+			remind_at       TIMESTAMP NOT NULL,
+			sent_at         TIMESTAMP,
+			failed_at       TIMESTAMP,
+			attempts        INTEGER DEFAULT 0 NOT NULL,
+			next_attempt_at TIMESTAMP,
+			locked_until    TIMESTAMP,
+			last_error      VARCHAR2(1000),
+			CONSTRAINT todo_reminders_unique UNIQUE (todo_id, offset_minutes, channel, target)
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS todo_reminders_due_idx ON TODO_REMINDERS (sent_at, remind_at)`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS WEBHOOKS (
+			id         INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
+			user_id    INTEGER NOT NULL REFERENCES USERS (id) ON DELETE CASCADE,
+			url        VARCHAR2(500) NOT NULL,
+			secret     VARCHAR2(128) NOT NULL,
+			events     VARCHAR2(200) NOT NULL,
+			active     NUMBER(1) DEFAULT 1 NOT NULL,
+			created_at TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS WEBHOOK_DELIVERIES (
+			id               INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
+			webhook_id       INTEGER NOT NULL REFERENCES WEBHOOKS (id) ON DELETE CASCADE,
+			event            VARCHAR2(50) NOT NULL,
+			payload          CLOB NOT NULL,
+			status           VARCHAR2(20) DEFAULT 'pending' NOT NULL,
+			attempts         INTEGER DEFAULT 0 NOT NULL,
+			next_attempt_at  TIMESTAMP,
+			locked_until     TIMESTAMP,
+			last_status_code INTEGER,
+			last_error       VARCHAR2(1000),
+			created_at       TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL,
+			delivered_at     TIMESTAMP
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS webhook_deliveries_status_idx ON WEBHOOK_DELIVERIES (status, next_attempt_at)`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Append-only log of every change made to a todo; rows are only ever inserted
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS TODO_AUDIT (
+			id         INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 1 INCREMENT BY 1) NOT NULL PRIMARY KEY,
+			todo_id    INTEGER NOT NULL,
+			user_id    INTEGER,
+			operation  VARCHAR2(20) NOT NULL,
+			changes    CLOB CHECK (changes IS JSON),
+			created_at TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`CREATE INDEX IF NOT EXISTS todo_audit_todo_idx ON TODO_AUDIT (todo_id, created_at)`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS CALENDAR_FEEDS (
+			user_id    INTEGER NOT NULL PRIMARY KEY REFERENCES USERS (id) ON DELETE CASCADE,
+			token_hash VARCHAR2(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return nil, nil, err
+	}
+
+	if err = initSync(); err != nil {
+		return nil, nil, err
+	}
+
 	return DB, RedisClient, nil
 }
 
+// initSync sets up the change log used by delta sync: every insert, update and hard delete of a todo adds a
+// row to TODO_CHANGES in the transaction making it, and updates bump the version of the todo. The rows are
+// numbered from TODOLIST_SYNC_SEQ only once they have committed, by services.GetChanges, so the numbers
+// follow the order in which changes became visible rather than the order they were written in.
+func initSync() error {
+	_, err := DB.Exec(`CREATE SEQUENCE IF NOT EXISTS TODOLIST_SYNC_SEQ`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE TABLE IF NOT EXISTS TODO_CHANGES (
+			todo_id  INTEGER NOT NULL,
+			owner_id INTEGER,
+			sync_seq INTEGER
+		)
+	`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return err
+	}
+
+	// The single row of TODO_SYNC_LOCK serializes the numbering of changes
+	_, err = DB.Exec(`CREATE TABLE IF NOT EXISTS TODO_SYNC_LOCK (id INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil && !isTableAlreadyExistsError(err) {
+		return err
+	}
+	_, err = DB.Exec(`INSERT INTO TODO_SYNC_LOCK (id) SELECT 1 FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM TODO_SYNC_LOCK)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE OR REPLACE TRIGGER TODOLIST_SYNC_TRG
+		BEFORE UPDATE ON TODOLIST FOR EACH ROW
+		BEGIN
+			:NEW.version := :OLD.version + 1;
+		END;
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		CREATE OR REPLACE TRIGGER TODOLIST_CHANGE_TRG
+		AFTER INSERT OR UPDATE OR DELETE ON TODOLIST FOR EACH ROW
+		BEGIN
+			IF DELETING THEN
+				INSERT INTO TODO_CHANGES (todo_id, owner_id) VALUES (:OLD.id, :OLD.owner_id);
+			ELSE
+				INSERT INTO TODO_CHANGES (todo_id, owner_id) VALUES (:NEW.id, :NEW.owner_id);
+			END IF;
+		END;
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Earlier versions numbered changes as they were written, in TODOLIST.sync_seq and a tombstone table.
+	// Todos keep their place in the feed; tombstones have no owner to deliver them to and are dropped.
+	for _, statement := range []string{
+		`DROP TRIGGER IF EXISTS TODOLIST_TOMBSTONE_TRG`,
+		`DROP TABLE IF EXISTS TODO_TOMBSTONES`,
+		`INSERT INTO TODO_CHANGES (todo_id, owner_id, sync_seq)
+		 SELECT id, owner_id, NVL(sync_seq, 0) FROM TODOLIST t
+		 WHERE NOT EXISTS (SELECT 1 FROM TODO_CHANGES c WHERE c.todo_id = t.id)`,
+		`CREATE INDEX IF NOT EXISTS todo_changes_owner_idx ON TODO_CHANGES (owner_id, sync_seq)`,
+		`CREATE INDEX IF NOT EXISTS todo_changes_todo_idx ON TODO_CHANGES (todo_id, sync_seq)`,
+	} {
+		if _, err = DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Helper function to identify if the table already exists
 func isTableAlreadyExistsError(err error) bool {
 	return err != nil && err.Error() == "ORA-00955" // ORA-00955 is Oracle's code for "name is already used by an existing object"
 }
+
+// Helper function to identify if the column being added already exists
+func isColumnAlreadyExistsError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ORA-01430") // ORA-01430 is Oracle's code for "column being added already exists in table"
+}
@@ -0,0 +1,659 @@
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"todolist/gql"
+	"todolist/helper"
+	"todolist/models"
+	"todolist/services"
+)
+
+// operation documents a single route. Request and response schemas are given as zero values of the Go types
+// the handlers decode and encode, so the generated schemas follow the code instead of being written by hand.
+type operation struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	// auth is the security scheme required by the route, if any
+	auth string
+	// idempotent routes honor the Idempotency-Key header
+	idempotent bool
+	params     []parameter
+	body       interface{}
+	bodyTypes  []string
+	responses  []response
+}
+
+type parameter struct {
+	name        string
+	in          string
+	kind        string
+	description string
+}
+
+type response struct {
+	status      int
+	description string
+	// schema is encoded as is, or wrapped in helper.ResponseData when enveloped
+	schema      interface{}
+	enveloped   bool
+	contentType string
+}
+
+const (
+	bearerAuth = "bearerAuth"
+	adminAuth  = "bearerAuth (admin role)"
+)
+
+// fieldMap stands for the fiber.Map payloads built by handlers
+type fieldMap map[string]interface{}
+
+var (
+	todoList   = models.TodoList{}
+	pathID     = parameter{name: "id", in: "path", kind: "integer", description: "ID of the todo"}
+	pageParam  = parameter{name: "page", in: "query", kind: "integer", description: "Page number, starting at 1"}
+	limitParam = parameter{name: "limit", in: "query", kind: "integer", description: "Number of items per page, 10 by default"}
+	// idempotencyKey is documented on idempotent operations
+	idempotencyKey = parameter{name: "Idempotency-Key", in: "header", kind: "string",
+		description: "Unique key making retries safe: the response to the first request with it is replayed for 24 hours"}
+)
+
+// messageResponse documents a helper.ResponseData without a task, as sent for errors and plain confirmations
+func messageResponse(status int, description string) response {
+	return response{status: status, description: description, enveloped: true}
+}
+
+// operations lists every route registered by router.Make
+var operations = []operation{
+	{method: "GET", path: "/todos", tag: "todos", summary: "List todos, a page at a time", auth: bearerAuth,
+		params: []parameter{pageParam, limitParam},
+		responses: []response{
+			{status: 200, description: "A page of todos", schema: services.PaginatedTodos{}},
+			messageResponse(500, "Failed to get todos"),
+		}},
+	{method: "POST", path: "/todos/bulk", tag: "todos", summary: "Create, update, complete or delete several todos at once", auth: bearerAuth, idempotent: true,
+		body: services.BulkRequest{},
+		responses: []response{
+			{status: 200, description: "Result of every operation", schema: []services.BulkResult{}, enveloped: true},
+			messageResponse(400, "Invalid bulk request"),
+			{status: 422, description: "An operation failed and the bulk request was rolled back", schema: []services.BulkResult{}, enveloped: true},
+		}},
+	{method: "GET", path: "/todos/changes", tag: "sync", summary: "Get the changes made to your todos since a sync token", auth: bearerAuth,
+		params: []parameter{
+			{name: "since", in: "query", kind: "string", description: "Sync token returned by the previous call; empty for a full sync"},
+			{name: "limit", in: "query", kind: "integer", description: "Maximum number of changes to return"},
+		},
+		responses: []response{
+			{status: 200, description: "A page of changes", schema: services.SyncChanges{}},
+			messageResponse(400, "Invalid sync token"),
+		}},
+	{method: "POST", path: "/todos/changes", tag: "sync", summary: "Push the changes made by a client while offline", auth: bearerAuth, idempotent: true,
+		body: services.SyncPushRequest{},
+		responses: []response{
+			{status: 200, description: "Result of every change, including conflicts", schema: []services.SyncResult{}, enveloped: true},
+			messageResponse(400, "Invalid sync request"),
+		}},
+	{method: "GET", path: "/todos/export", tag: "transfer", summary: "Export your todos", auth: bearerAuth,
+		params: []parameter{
+			{name: "format", in: "query", kind: "string", description: "csv (default), json, todotxt or markdown"},
+			{name: "status", in: "query", kind: "string", description: "Only export todos with this status"},
+			{name: "due_from", in: "query", kind: "string", description: "Only export todos due on or after this date (YYYY-MM-DD)"},
+			{name: "due_to", in: "query", kind: "string", description: "Only export todos due on or before this date (YYYY-MM-DD)"},
+			{name: "q", in: "query", kind: "string", description: "Only export todos whose title contains this text"},
+		},
+		responses: []response{
+			{status: 200, description: "The exported todos", schema: "", contentType: "text/csv"},
+			messageResponse(400, "Invalid export format or filter"),
+		}},
+	{method: "POST", path: "/todos/import", tag: "transfer", summary: "Import todos", auth: bearerAuth, idempotent: true,
+		params: []parameter{
+			{name: "format", in: "query", kind: "string", description: "csv, json, ics, todotxt or markdown; taken from Content-Type when omitted"},
+			{name: "map", in: "query", kind: "string", description: "Column mapping as Column:field pairs separated by commas"},
+			{name: "dry_run", in: "query", kind: "boolean", description: "Validate the rows without importing them"},
+		},
+		body:      "",
+		bodyTypes: []string{"text/csv", "application/json", "text/calendar", "text/markdown", "text/plain"},
+		responses: []response{
+			{status: 201, description: "Todos imported", schema: services.ImportReport{}, enveloped: true},
+			{status: 200, description: "Dry run succeeded", schema: services.ImportReport{}, enveloped: true},
+			messageResponse(400, "Invalid format or mapping"),
+			{status: 422, description: "Some rows are invalid and nothing was imported", schema: services.ImportReport{}, enveloped: true},
+		}},
+	{method: "GET", path: "/todos/stream", tag: "todos", summary: "Stream todo changes as server-sent events", auth: bearerAuth,
+		params: []parameter{
+			{name: "access_token", in: "query", kind: "string", description: "Token for clients such as EventSource that cannot set the Authorization header"},
+			{name: "last_event_id", in: "query", kind: "string", description: "Replay the events after this one; the Last-Event-ID header takes precedence"},
+		},
+		responses: []response{
+			{status: 200, description: "A stream of events about the todos of the user, each encoded as a TodoEvent", schema: "", contentType: "text/event-stream"},
+		}},
+	{method: "POST", path: "/graphql", tag: "graphql", summary: "Run a GraphQL query or mutation on todos, nesting fields at most 10 levels deep", auth: bearerAuth,
+		body: gql.Request{},
+		responses: []response{
+			{status: 200, description: "The GraphQL result; field errors are listed in errors", schema: struct {
+				Data   fieldMap      `json:"data"`
+				Errors []interface{} `json:"errors,omitempty"`
+			}{}},
+			messageResponse(400, "Invalid request body or missing query"),
+		}},
+	{method: "GET", path: "/todo/{id}", tag: "todos", summary: "Get a todo with its progress and checklist", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			{status: 200, description: "The todo", schema: struct {
+				Todo      *models.TodoList       `json:"todo"`
+				Progress  services.TodoProgress  `json:"progress"`
+				Checklist []models.ChecklistItem `json:"checklist"`
+			}{}},
+			messageResponse(500, "Failed to get todo"),
+		}},
+	{method: "POST", path: "/todo", tag: "todos", summary: "Create a todo", auth: bearerAuth, idempotent: true,
+		body: todoList,
+		responses: []response{
+			{status: 201, description: "Todo created", schema: todoList, enveloped: true},
+			messageResponse(400, "Invalid request body"),
+			messageResponse(422, "The parent todo does not exist or is in the trash"),
+			messageResponse(500, "Failed to create todo"),
+		}},
+	{method: "PUT", path: "/todo/{id}", tag: "todos", summary: "Update a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		body:   todoList,
+		responses: []response{
+			{status: 200, description: "Todo updated", schema: todoList, enveloped: true},
+			messageResponse(400, "Invalid request body"),
+			messageResponse(404, "Todo not found"),
+		}},
+	{method: "DELETE", path: "/todo/{id}", tag: "todos", summary: "Move a todo to the trash", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			messageResponse(200, "Todo moved to trash"),
+			messageResponse(404, "Todo not found"),
+		}},
+	{method: "GET", path: "/todo/{id}/history", tag: "audit", summary: "Get the change history of a todo", auth: bearerAuth,
+		params: []parameter{pathID, pageParam, limitParam},
+		responses: []response{
+			{status: 200, description: "A page of audit events", schema: services.PaginatedAuditEvents{}},
+		}},
+	{method: "GET", path: "/todo/{id}/occurrences", tag: "recurrence", summary: "Preview the upcoming due dates of a recurring todo", auth: bearerAuth,
+		params: []parameter{pathID, {name: "count", in: "query", kind: "integer", description: "Number of occurrences, 5 by default"}},
+		responses: []response{
+			{status: 200, description: "Upcoming due dates", schema: struct {
+				Occurrences []time.Time `json:"occurrences"`
+			}{}},
+			messageResponse(404, "Todo not found"),
+		}},
+	{method: "GET", path: "/todo/{id}/reminders", tag: "reminders", summary: "List the reminders of a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			{status: 200, description: "The reminders", schema: struct {
+				Reminders []models.Reminder `json:"reminders"`
+			}{}},
+		}},
+	{method: "POST", path: "/todo/{id}/reminders", tag: "reminders", summary: "Add a reminder to a todo", auth: bearerAuth, idempotent: true,
+		params: []parameter{pathID},
+		body:   services.ReminderInput{},
+		responses: []response{
+			{status: 201, description: "Reminder created", schema: models.Reminder{}, enveloped: true},
+			messageResponse(400, "Invalid reminder"),
+			messageResponse(404, "Todo not found"),
+			messageResponse(422, "The webhook target resolves to an internal address, or the channel is not available"),
+		}},
+	{method: "DELETE", path: "/todo/{id}/reminders/{reminderId}", tag: "reminders", summary: "Delete a reminder", auth: bearerAuth,
+		params: []parameter{pathID, {name: "reminderId", in: "path", kind: "integer", description: "ID of the reminder"}},
+		responses: []response{
+			messageResponse(200, "Reminder deleted"),
+			messageResponse(404, "Reminder not found"),
+		}},
+	{method: "GET", path: "/todo/{id}/subtasks", tag: "subtasks", summary: "List the subtasks of a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			{status: 200, description: "The subtasks in order", schema: struct {
+				Subtasks []models.TodoList `json:"subtasks"`
+			}{}},
+		}},
+	{method: "POST", path: "/todo/{id}/subtasks", tag: "subtasks", summary: "Add a subtask to a todo", auth: bearerAuth, idempotent: true,
+		params: []parameter{pathID},
+		body:   todoList,
+		responses: []response{
+			{status: 201, description: "Subtask created", schema: todoList, enveloped: true},
+			messageResponse(400, "Invalid subtask"),
+			messageResponse(404, "Todo not found"),
+		}},
+	{method: "PUT", path: "/todo/{id}/subtasks/order", tag: "subtasks", summary: "Reorder the subtasks of a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		body: struct {
+			IDs []int `json:"ids"`
+		}{},
+		responses: []response{
+			messageResponse(200, "Subtasks reordered"),
+			messageResponse(400, "The IDs do not match the subtasks of the todo"),
+		}},
+	{method: "GET", path: "/todo/{id}/checklist", tag: "subtasks", summary: "Get the checklist of a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			{status: 200, description: "The checklist items in order", schema: struct {
+				Checklist []models.ChecklistItem `json:"checklist"`
+			}{}},
+		}},
+	{method: "POST", path: "/todo/{id}/checklist", tag: "subtasks", summary: "Add a checklist item to a todo", auth: bearerAuth, idempotent: true,
+		params: []parameter{pathID},
+		body: struct {
+			Content string `json:"content"`
+		}{},
+		responses: []response{
+			{status: 201, description: "Checklist item added", schema: models.ChecklistItem{}, enveloped: true},
+			messageResponse(404, "Todo not found"),
+		}},
+	{method: "PUT", path: "/todo/{id}/checklist/order", tag: "subtasks", summary: "Reorder the checklist of a todo", auth: bearerAuth,
+		params: []parameter{pathID},
+		body: struct {
+			IDs []int `json:"ids"`
+		}{},
+		responses: []response{
+			messageResponse(200, "Checklist reordered"),
+			messageResponse(400, "The IDs do not match the checklist of the todo"),
+		}},
+	{method: "POST", path: "/todo/{id}/checklist/{itemId}/toggle", tag: "subtasks", summary: "Tick or untick a checklist item", auth: bearerAuth, idempotent: true,
+		params: []parameter{pathID, {name: "itemId", in: "path", kind: "integer", description: "ID of the checklist item"}},
+		responses: []response{
+			{status: 200, description: "Checklist item toggled", schema: models.ChecklistItem{}, enveloped: true},
+			messageResponse(404, "Checklist item not found"),
+		}},
+	{method: "DELETE", path: "/todo/{id}/checklist/{itemId}", tag: "subtasks", summary: "Delete a checklist item", auth: bearerAuth,
+		params: []parameter{pathID, {name: "itemId", in: "path", kind: "integer", description: "ID of the checklist item"}},
+		responses: []response{
+			messageResponse(200, "Checklist item deleted"),
+			messageResponse(404, "Checklist item not found"),
+		}},
+	{method: "GET", path: "/trash", tag: "trash", summary: "List the todos in the trash", auth: bearerAuth,
+		params: []parameter{pageParam, limitParam},
+		responses: []response{
+			{status: 200, description: "A page of deleted todos", schema: services.PaginatedTodos{}},
+		}},
+	{method: "POST", path: "/trash/{id}/restore", tag: "trash", summary: "Restore a todo from the trash", auth: bearerAuth, idempotent: true,
+		params: []parameter{pathID},
+		responses: []response{
+			messageResponse(200, "Todo restored"),
+			messageResponse(404, "Todo not found in trash"),
+		}},
+	{method: "DELETE", path: "/trash/{id}", tag: "trash", summary: "Permanently delete a todo from the trash", auth: bearerAuth,
+		params: []parameter{pathID},
+		responses: []response{
+			messageResponse(200, "Todo permanently deleted"),
+			messageResponse(404, "Todo not found in trash"),
+		}},
+	{method: "GET", path: "/audit", tag: "audit", summary: "Search the audit log", auth: adminAuth,
+		params: []parameter{
+			pageParam, limitParam,
+			{name: "todo_id", in: "query", kind: "integer", description: "Only return events of this todo"},
+			{name: "user_id", in: "query", kind: "integer", description: "Only return events caused by this user"},
+			{name: "operation", in: "query", kind: "string", description: "Only return events of this operation"},
+			{name: "from", in: "query", kind: "string", description: "Only return events at or after this RFC 3339 timestamp"},
+			{name: "to", in: "query", kind: "string", description: "Only return events before this RFC 3339 timestamp"},
+		},
+		responses: []response{
+			{status: 200, description: "A page of audit events", schema: services.PaginatedAuditEvents{}},
+			messageResponse(400, "Invalid timestamp"),
+			messageResponse(403, "Admin access required"),
+		}},
+	{method: "GET", path: "/webhooks", tag: "webhooks", summary: "List your webhooks", auth: bearerAuth,
+		responses: []response{
+			{status: 200, description: "The webhooks", schema: struct {
+				Webhooks []models.Webhook `json:"webhooks"`
+			}{}},
+		}},
+	{method: "POST", path: "/webhooks", tag: "webhooks", summary: "Register a webhook", auth: bearerAuth, idempotent: true,
+		body: services.WebhookInput{},
+		responses: []response{
+			{status: 201, description: "Webhook created; the secret is only returned here", schema: models.Webhook{}, enveloped: true},
+			messageResponse(400, "Invalid webhook"),
+			messageResponse(422, "The URL resolves to an internal address"),
+		}},
+	{method: "DELETE", path: "/webhooks/{id}", tag: "webhooks", summary: "Delete a webhook", auth: bearerAuth,
+		params: []parameter{{name: "id", in: "path", kind: "integer", description: "ID of the webhook"}},
+		responses: []response{
+			messageResponse(200, "Webhook deleted"),
+			messageResponse(404, "Webhook not found"),
+		}},
+	{method: "GET", path: "/webhooks/{id}/deliveries", tag: "webhooks", summary: "List the deliveries of a webhook", auth: bearerAuth,
+		params: []parameter{{name: "id", in: "path", kind: "integer", description: "ID of the webhook"}, pageParam, limitParam},
+		responses: []response{
+			{status: 200, description: "A page of deliveries", schema: services.PaginatedWebhookDeliveries{}},
+			messageResponse(404, "Webhook not found"),
+		}},
+	{method: "POST", path: "/webhooks/{id}/deliveries/{deliveryId}/redeliver", tag: "webhooks", summary: "Queue a delivery again", auth: bearerAuth, idempotent: true,
+		params: []parameter{
+			{name: "id", in: "path", kind: "integer", description: "ID of the webhook"},
+			{name: "deliveryId", in: "path", kind: "integer", description: "ID of the delivery"},
+		},
+		responses: []response{
+			{status: 202, description: "Redelivery queued", schema: struct {
+				DeliveryID int `json:"delivery_id"`
+			}{}, enveloped: true},
+			messageResponse(404, "Webhook delivery not found"),
+		}},
+	{method: "POST", path: "/calendar/token", tag: "calendar", summary: "Create or replace the secret URL of your calendar feed", auth: bearerAuth, idempotent: true,
+		responses: []response{
+			{status: 201, description: "Calendar feed created; the token is only returned here", schema: struct {
+				Token string `json:"token"`
+				URL   string `json:"url"`
+			}{}, enveloped: true},
+		}},
+	{method: "DELETE", path: "/calendar/token", tag: "calendar", summary: "Revoke your calendar feed", auth: bearerAuth,
+		responses: []response{
+			messageResponse(200, "Calendar feed deleted"),
+			messageResponse(404, "Calendar feed not found"),
+		}},
+	{method: "GET", path: "/calendar/{token}/todos.ics", tag: "calendar", summary: "Get your todos with a due date as an iCalendar feed",
+		params: []parameter{
+			{name: "token", in: "path", kind: "string", description: "Secret token of the feed"},
+			{name: "events", in: "query", kind: "boolean", description: "Also include every todo as an all-day event"},
+		},
+		responses: []response{
+			{status: 200, description: "The iCalendar feed", schema: "", contentType: "text/calendar"},
+			messageResponse(404, "Calendar feed not found"),
+		}},
+	{method: "POST", path: "/login", tag: "auth", summary: "Log in and get a bearer token",
+		body: struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}{},
+		responses: []response{
+			{status: 200, description: "Login successful", schema: struct {
+				Token string `json:"token"`
+			}{}, enveloped: true},
+			messageResponse(401, "Invalid password"),
+			messageResponse(404, "User not found"),
+		}},
+	{method: "POST", path: "/register", tag: "auth", summary: "Create a user",
+		body: struct {
+			Username string
+			Password string
+		}{},
+		responses: []response{
+			{status: 201, description: "User created", schema: fieldMap{}, enveloped: true},
+			messageResponse(500, "Failed to create user"),
+		}},
+	{method: "GET", path: "/openapi.json", tag: "docs", summary: "Get this OpenAPI document",
+		responses: []response{
+			{status: 200, description: "The OpenAPI document", schema: fieldMap{}},
+		}},
+	{method: "GET", path: "/docs", tag: "docs", summary: "Browse this OpenAPI document",
+		responses: []response{
+			{status: 200, description: "An interactive documentation page", schema: "", contentType: "text/html"},
+		}},
+	{method: "GET", path: "/docs/{file}", tag: "docs", summary: "Get a script or stylesheet of the documentation page",
+		params: []parameter{{name: "file", in: "path", kind: "string", description: "swagger-ui.css or swagger-ui-bundle.js"}},
+		responses: []response{
+			{status: 200, description: "The asset", schema: ""},
+			messageResponse(404, "Asset not found"),
+		}},
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+	specErr  error
+)
+
+// Spec returns the OpenAPI 3 document of the API, generated once from the route table and the Go types
+func Spec() ([]byte, error) {
+	specOnce.Do(func() {
+		specJSON, specErr = json.MarshalIndent(buildSpec(), "", "  ")
+	})
+	return specJSON, specErr
+}
+
+func buildSpec() map[string]interface{} {
+	schemas := newSchemaRegistry()
+	paths := map[string]map[string]interface{}{}
+
+	for _, op := range operations {
+		item := paths[op.path]
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = op.build(schemas)
+	}
+
+	// Payloads sent on the event stream and to webhooks are not the body of any route, but clients decode them too
+	schemas.schemaOf(reflect.TypeOf(services.TodoEvent{}))
+	schemas.schemaOf(reflect.TypeOf(services.WebhookPayload{}))
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Todo List API",
+			"version":     "1.0.0",
+			"description": "Todo items are encoded as returned by the server: field names are capitalized and nullable columns such as DueDate are objects with a Time and a Valid field.",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/api/v1"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				bearerAuth: map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func (op operation) build(schemas *schemaRegistry) map[string]interface{} {
+	result := map[string]interface{}{
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+		"operationId": operationID(op.method, op.path),
+	}
+
+	switch op.auth {
+	case bearerAuth, adminAuth:
+		result["security"] = []interface{}{map[string]interface{}{bearerAuth: []string{}}}
+		if op.auth == adminAuth {
+			result["description"] = "Requires a user with the admin role."
+		}
+	default:
+		result["security"] = []interface{}{}
+	}
+
+	params := op.params
+	if op.idempotent {
+		params = append(params, idempotencyKey)
+	}
+	if len(params) > 0 {
+		var encoded []interface{}
+		for _, p := range params {
+			encoded = append(encoded, map[string]interface{}{
+				"name":        p.name,
+				"in":          p.in,
+				"required":    p.in == "path",
+				"description": p.description,
+				"schema":      map[string]interface{}{"type": p.kind},
+			})
+		}
+		result["parameters"] = encoded
+	}
+
+	if op.body != nil {
+		content := map[string]interface{}{}
+		if len(op.bodyTypes) > 0 {
+			for _, contentType := range op.bodyTypes {
+				content[contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+			}
+		} else {
+			content["application/json"] = map[string]interface{}{"schema": schemas.schemaOf(reflect.TypeOf(op.body))}
+		}
+		result["requestBody"] = map[string]interface{}{"required": true, "content": content}
+	}
+
+	responses := map[string]interface{}{}
+	if op.auth != "" {
+		responses["401"] = map[string]interface{}{
+			"description": "Missing or invalid token",
+			"content":     jsonContent(schemas.envelope(nil)),
+		}
+	}
+	if op.idempotent {
+		responses["409"] = map[string]interface{}{
+			"description": "A request with the same Idempotency-Key is still being processed",
+			"content":     jsonContent(schemas.envelope(nil)),
+		}
+		if _, documented := responses["422"]; !documented {
+			responses["422"] = map[string]interface{}{
+				"description": "The Idempotency-Key was already used for a different request",
+				"content":     jsonContent(schemas.envelope(nil)),
+			}
+		}
+	}
+	// Every route is rate limited by router.Make
+	responses["429"] = map[string]interface{}{
+		"description": "Rate limit exceeded, retry after the number of seconds in the Retry-After header",
+		"headers": map[string]interface{}{
+			"Retry-After": map[string]interface{}{"schema": map[string]interface{}{"type": "integer"}},
+		},
+		"content": jsonContent(schemas.envelope(nil)),
+	}
+	for _, r := range op.responses {
+		var content map[string]interface{}
+		switch {
+		case r.contentType != "":
+			content = map[string]interface{}{r.contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		case r.enveloped:
+			content = jsonContent(schemas.envelope(r.schema))
+		default:
+			content = jsonContent(schemas.schemaOf(reflect.TypeOf(r.schema)))
+		}
+		responses[fmt.Sprint(r.status)] = map[string]interface{}{"description": r.description, "content": content}
+	}
+	result["responses"] = responses
+
+	return result
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// operationID derives a stable identifier such as getTodoIdChecklist from a method and path
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return strings.ContainsRune("/{}.", r) }) {
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
+
+// schemaRegistry converts Go types into JSON schemas, collecting named structs as reusable components
+type schemaRegistry struct {
+	components map[string]interface{}
+	types      map[string]reflect.Type
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: map[string]interface{}{}, types: map[string]reflect.Type{}}
+}
+
+// envelope describes a helper.ResponseData whose task holds a value of the type of payload, or null
+func (s *schemaRegistry) envelope(payload interface{}) interface{} {
+	ref := s.schemaOf(reflect.TypeOf(helper.ResponseData{}))
+	if payload == nil {
+		return ref
+	}
+	return map[string]interface{}{
+		"allOf": []interface{}{
+			ref,
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"task": s.schemaOf(reflect.TypeOf(payload))},
+			},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s *schemaRegistry) schemaOf(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]interface{}{"allOf": []interface{}{s.schemaOf(t.Elem())}, "nullable": true}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.component(t)
+	default:
+		panic(fmt.Sprintf("docs: cannot describe %s", t))
+	}
+}
+
+// component registers a named struct under its type name and returns a reference to it
+func (s *schemaRegistry) component(t reflect.Type) map[string]interface{} {
+	name := t.Name()
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+
+	if existing, ok := s.types[name]; ok {
+		if existing != t {
+			panic(fmt.Sprintf("docs: %s and %s share the schema name %s", existing, t, name))
+		}
+		return ref
+	}
+
+	// Register the type before describing it so self-referencing structs terminate
+	s.types[name] = t
+	s.components[name] = s.structSchema(t)
+	return ref
+}
+
+// structSchema describes the fields of a struct as encoding/json would encode them
+func (s *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = s.schemaOf(field.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
@@ -0,0 +1,50 @@
+package docs
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"io/fs"
+	"regexp"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// Page is the interactive documentation page, which renders the document served next to it at openapi.json
+//
+//go:embed index.html
+var Page []byte
+
+// PageCSP is the Content-Security-Policy of Page. It only loads the Swagger UI assets served by this API
+// and runs the inline script of the page, identified by its hash.
+var PageCSP = pageCSP()
+
+var inlineScript = regexp.MustCompile(`<script>([\s\S]*?)</script>`)
+
+func pageCSP() string {
+	script := inlineScript.FindSubmatch(Page)[1]
+	hash := sha256.Sum256(script)
+	return "default-src 'none'; script-src 'self' 'sha256-" + base64.StdEncoding.EncodeToString(hash[:]) + "'; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; " +
+		"frame-ancestors 'none'; base-uri 'none'"
+}
+
+// assetTypes are the content types of the Swagger UI files Page loads. They are embedded in the binary from
+// the module version pinned in go.mod, so the page depends on no CDN.
+var assetTypes = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// Asset returns the content and content type of a file loaded by Page, or false for any other name
+func Asset(name string) ([]byte, string, bool) {
+	contentType, ok := assetTypes[name]
+	if !ok {
+		return nil, "", false
+	}
+	content, err := fs.ReadFile(swaggerFiles.FS, name)
+	if err != nil {
+		return nil, "", false
+	}
+	return content, contentType, true
+}
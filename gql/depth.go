@@ -0,0 +1,67 @@
+package gql
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// MaxDepth is the deepest nesting of fields a query may select. Parents and subtasks lead to todos again,
+// so without a limit a short query can make the server load the same todos over and over.
+var MaxDepth = 10
+
+// checkDepth returns an error when an operation of query nests fields deeper than MaxDepth. Queries that do
+// not parse pass, so that execution reports the syntax error.
+func checkDepth(query string) error {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		return nil
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if depth := selectionDepth(operation.SelectionSet, fragments, map[string]bool{}); depth > MaxDepth {
+				return fmt.Errorf("query is nested %d levels deep, the limit is %d", depth, MaxDepth)
+			}
+		}
+	}
+	return nil
+}
+
+// selectionDepth returns how deep the fields of set nest, following fragments. visiting holds the fragments
+// being expanded, so that cyclic spreads, which validation rejects later, end the walk.
+func selectionDepth(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visiting map[string]bool) int {
+	if set == nil {
+		return 0
+	}
+
+	deepest := 0
+	for _, selection := range set.Selections {
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			depth = 1 + selectionDepth(selection.SelectionSet, fragments, visiting)
+		case *ast.InlineFragment:
+			depth = selectionDepth(selection.SelectionSet, fragments, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			if fragment, ok := fragments[name]; ok && !visiting[name] {
+				visiting[name] = true
+				depth = selectionDepth(fragment.SelectionSet, fragments, visiting)
+				delete(visiting, name)
+			}
+		}
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest
+}
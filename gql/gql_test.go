@@ -0,0 +1,152 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/database"
+	"todolist/services"
+)
+
+func TestLoaderBatchesKeys(t *testing.T) {
+	var batches [][]int
+	l := newLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		batches = append(batches, keys)
+		values := map[int]string{}
+		for _, key := range keys {
+			if key != 3 {
+				values[key] = string(rune('a' + key))
+			}
+		}
+		return values, nil
+	})
+
+	ctx := context.Background()
+	thunks := []func() (string, error){l.load(ctx, 1), l.load(ctx, 2), l.load(ctx, 1), l.load(ctx, 3)}
+	assert.Empty(t, batches, "nothing is fetched until a value is needed")
+
+	var values []string
+	for _, thunk := range thunks {
+		value, err := thunk()
+		require.NoError(t, err)
+		values = append(values, value)
+	}
+	assert.Equal(t, []string{"b", "c", "b", ""}, values)
+	assert.Equal(t, [][]int{{1, 2, 3}}, batches)
+
+	// Values already fetched are reused; new keys start a new batch
+	value, _ := l.load(ctx, 2)()
+	assert.Equal(t, "c", value)
+	value, _ = l.load(ctx, 4)()
+	assert.Equal(t, "e", value)
+	assert.Equal(t, [][]int{{1, 2, 3}, {4}}, batches)
+}
+
+func TestLoaderReportsErrorsToTheWholeBatch(t *testing.T) {
+	l := newLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		return nil, errors.New("database is down")
+	})
+
+	first, second := l.load(context.Background(), 1), l.load(context.Background(), 2)
+	_, err := first()
+	assert.EqualError(t, err, "database is down")
+	_, err = second()
+	assert.EqualError(t, err, "database is down")
+}
+
+func TestSchemaAcceptsQueries(t *testing.T) {
+	queries := []string{
+		`{ todos(page: 1, limit: 5, status: "pending", dueFrom: "2024-01-01") {
+			todos { id title dueDate owner { username } parent { id } subtasks { id title checklist { content done } } checklist { id } }
+			currentPage totalPages totalTodos
+		} }`,
+		`{ todo(id: 1) { id priority recurrence autoComplete version } me { id username role } }`,
+		`mutation { createTodo(input: {title: "Write", description: "Docs", status: "pending", dueDate: "2024-05-01"}) { id } }`,
+		`mutation { updateTodo(id: 1, input: {title: "Write", description: "Docs", status: "completed"}) { id version } }`,
+		`mutation { completeTodo(id: 1) { status } deleteTodo(id: 2) }`,
+	}
+
+	for _, query := range queries {
+		document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+		require.NoError(t, err, query)
+		result := graphql.ValidateDocument(&schema, document, nil)
+		assert.True(t, result.IsValid, "%s: %v", query, result.Errors)
+	}
+}
+
+func TestOwnersAreLoadedInOneQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		db.Close()
+		database.DB = previous
+	})
+
+	columns := []string{"id", "title", "description", "status", "due_date", "deleted_at", "parent_id", "position",
+		"auto_complete", "recurrence", "occurrence", "version", "priority", "owner_id"}
+	rows := sqlmock.NewRows(columns)
+	for id := 1; id <= 3; id++ {
+		rows.AddRow(id, "Todo", "", "pending", nil, nil, nil, id, 0, nil, 1, 1, nil, 5)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM todolist")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todolist WHERE deleted_at IS NULL AND owner_id = :1 ORDER BY id")).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username, role FROM users WHERE id IN (:1)")).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role"}).AddRow(5, "alice", "user"))
+
+	ctx := services.WithActor(context.Background(), 5)
+	result := Execute(ctx, Request{Query: "{ todos { todos { id owner { id username } } } }"})
+	require.Empty(t, result.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	todos := result.Data.(map[string]interface{})["todos"].(map[string]interface{})["todos"].([]interface{})
+	require.Len(t, todos, 3)
+	for _, todo := range todos {
+		assert.Equal(t, map[string]interface{}{"id": 5, "username": "alice"}, todo.(map[string]interface{})["owner"])
+	}
+}
+
+func TestTodoFromInput(t *testing.T) {
+	todo, err := todoFromInput(map[string]interface{}{
+		"title": "Write", "description": "Docs", "status": "pending", "dueDate": "2024-05-01", "parentId": 3, "priority": "A",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-05-01", todo.DueDate.Time.Format("2006-01-02"))
+	assert.Equal(t, int64(3), todo.ParentID.Int64)
+	assert.Equal(t, "A", todo.Priority)
+
+	_, err = todoFromInput(map[string]interface{}{"title": "Write", "description": "Docs", "status": "pending", "dueDate": "May 1st"})
+	assert.Error(t, err)
+}
+
+func TestExecuteRefusesDeepQueries(t *testing.T) {
+	nested := "id"
+	for i := 0; i < MaxDepth; i++ {
+		nested = "subtasks { " + nested + " }"
+	}
+	assert.NoError(t, checkDepth("{ todo(id: 1) { id } }"))
+	assert.NoError(t, checkDepth("{ todos { todos { parent { parent { id } } } } }"))
+
+	result := Execute(context.Background(), Request{Query: "{ todo(id: 1) { " + nested + " } }"})
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "the limit is 10")
+	assert.Nil(t, result.Data)
+
+	// Fragments count towards the depth where they are spread
+	fragments := `{ todo(id: 1) { ...deep } }
+		fragment deep on Todo { subtasks { subtasks { subtasks { subtasks { subtasks { ...deeper } } } } } }
+		fragment deeper on Todo { subtasks { subtasks { subtasks { subtasks { id } } } } }`
+	assert.Error(t, checkDepth(fragments))
+	assert.NoError(t, checkDepth(`{ todo(id: 1) { ...self } } fragment self on Todo { id subtasks { ...self } }`))
+}
@@ -0,0 +1,82 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"todolist/models"
+	"todolist/services"
+)
+
+// loader batches the keys requested while a query is resolved into a single fetch, DataLoader style.
+// load only queues a key and returns a thunk; graphql-go runs thunks once every field at the current depth
+// has been resolved, so the subtasks of every todo in a list are read with one query instead of one per todo.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	results map[K]loaderResult[V]
+}
+
+type loaderResult[V any] struct {
+	value V
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, queued: map[K]bool{}, results: map[K]loaderResult[V]{}}
+}
+
+// load queues key for the next batch and returns a function waiting for its value.
+// Keys missing from the fetched values resolve to the zero value of V.
+func (l *loader[K, V]) load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if _, done := l.results[key]; !done && !l.queued[key] {
+		l.pending = append(l.pending, key)
+		l.queued[key] = true
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, done := l.results[key]; !done {
+			keys := l.pending
+			l.pending, l.queued = nil, map[K]bool{}
+
+			values, err := l.fetch(ctx, keys)
+			for _, k := range keys {
+				l.results[k] = loaderResult[V]{value: values[k], err: err}
+			}
+		}
+
+		result := l.results[key]
+		return result.value, result.err
+	}
+}
+
+// loaders holds the loaders of a single request, so cached values never outlive it
+type loaders struct {
+	todos      *loader[int, *models.TodoList]
+	subtasks   *loader[int, []models.TodoList]
+	checklists *loader[int, []models.ChecklistItem]
+	users      *loader[int, *models.User]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		todos:      newLoader(services.GetTodosByIDs),
+		subtasks:   newLoader(services.GetSubtasksByParents),
+		checklists: newLoader(services.GetChecklistsByTodos),
+		users:      newLoader(services.GetUsersByIDs),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
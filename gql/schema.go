@@ -0,0 +1,363 @@
+// Package gql serves todos over GraphQL, resolving related todos and checklists in batches
+package gql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"todolist/models"
+	"todolist/services"
+)
+
+// Request is the body of a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Execute runs a GraphQL request. ctx should carry the authenticated user, as set by services.WithActor.
+// Queries nesting fields deeper than MaxDepth are refused without running.
+func Execute(ctx context.Context, req Request) *graphql.Result {
+	if err := checkDepth(req.Query); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        withLoaders(ctx),
+	})
+}
+
+var schema graphql.Schema
+
+var checklistItemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ChecklistItem",
+	Fields: graphql.Fields{
+		"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"content":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"done":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"position": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"role":     &graphql.Field{Type: graphql.String},
+	},
+})
+
+// todoType exposes models.TodoList; fields other than those resolved below are read by name
+var todoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Todo",
+	Fields: graphql.Fields{
+		"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"title":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"status":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"priority":     &graphql.Field{Type: graphql.String},
+		"recurrence":   &graphql.Field{Type: graphql.String},
+		"autoComplete": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"position":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"occurrence":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"version":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"dueDate": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Due date as YYYY-MM-DD",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if todo := sourceTodo(p); todo.DueDate.Valid {
+					return todo.DueDate.Time.Format("2006-01-02"), nil
+				}
+				return nil, nil
+			},
+		},
+		"parentId": &graphql.Field{
+			Type: graphql.Int,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if todo := sourceTodo(p); todo.ParentID.Valid {
+					return todo.ParentID.Int64, nil
+				}
+				return nil, nil
+			},
+		},
+		"checklist": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(checklistItemType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				load := loadersFrom(p.Context).checklists.load(p.Context, sourceTodo(p).ID)
+				return func() (interface{}, error) {
+					items, err := load()
+					if items == nil {
+						items = []models.ChecklistItem{}
+					}
+					return items, err
+				}, nil
+			},
+		},
+		"owner": &graphql.Field{
+			Type: userType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				todo := sourceTodo(p)
+				if !todo.OwnerID.Valid {
+					return nil, nil
+				}
+				load := loadersFrom(p.Context).users.load(p.Context, int(todo.OwnerID.Int64))
+				return func() (interface{}, error) {
+					owner, err := load()
+					if err != nil || owner == nil {
+						return nil, err
+					}
+					return owner, nil
+				}, nil
+			},
+		},
+	},
+})
+
+// The fields of a todo leading to other todos refer to todoType itself, so they are added once it exists,
+// before the schema is built
+func init() {
+	todoType.AddFieldConfig("parent", &graphql.Field{
+		Type: todoType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			todo := sourceTodo(p)
+			if !todo.ParentID.Valid {
+				return nil, nil
+			}
+			load := loadersFrom(p.Context).todos.load(p.Context, int(todo.ParentID.Int64))
+			return func() (interface{}, error) {
+				parent, err := load()
+				if err != nil || parent == nil {
+					return nil, err
+				}
+				return parent, nil
+			}, nil
+		},
+	})
+	todoType.AddFieldConfig("subtasks", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(todoType))),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			load := loadersFrom(p.Context).subtasks.load(p.Context, sourceTodo(p).ID)
+			return func() (interface{}, error) {
+				subtasks, err := load()
+				if subtasks == nil {
+					subtasks = []models.TodoList{}
+				}
+				return subtasks, err
+			}, nil
+		},
+	})
+
+	schema = mustSchema()
+}
+
+var todoPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TodoPage",
+	Fields: graphql.Fields{
+		"todos":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(todoType)))},
+		"currentPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"totalPages":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"totalTodos": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*services.PaginatedTodos).TotalTasks, nil
+			},
+		},
+	},
+})
+
+var todoInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "TodoInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"title":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"description":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"status":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"dueDate":      &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Due date as YYYY-MM-DD"},
+		"priority":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"recurrence":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"autoComplete": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"parentId":     &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var idArgs = graphql.FieldConfigArgument{
+	"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+}
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"todos": &graphql.Field{
+			Type: graphql.NewNonNull(todoPageType),
+			Args: graphql.FieldConfigArgument{
+				"page":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+				"limit":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+				"status":  &graphql.ArgumentConfig{Type: graphql.String},
+				"search":  &graphql.ArgumentConfig{Type: graphql.String},
+				"dueFrom": &graphql.ArgumentConfig{Type: graphql.String, Description: "Only todos due on or after this date (YYYY-MM-DD)"},
+				"dueTo":   &graphql.ArgumentConfig{Type: graphql.String, Description: "Only todos due on or before this date (YYYY-MM-DD)"},
+			},
+			Resolve: resolveTodos,
+		},
+		"todo": &graphql.Field{
+			Type: todoType,
+			Args: idArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				todo, err := services.GetTodoByID(p.Context, strconv.Itoa(p.Args["id"].(int)))
+				if err != nil || todo == nil {
+					return nil, err
+				}
+				return todo, nil
+			},
+		},
+		"me": &graphql.Field{
+			Type: graphql.NewNonNull(userType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID, ok := services.ActorFromContext(p.Context)
+				if !ok {
+					return nil, errors.New("not authenticated")
+				}
+				return services.GetUserByID(p.Context, userID)
+			},
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createTodo": &graphql.Field{
+			Type: graphql.NewNonNull(todoType),
+			Args: graphql.FieldConfigArgument{
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(todoInputType)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				todo, err := todoFromInput(p.Args["input"])
+				if err != nil {
+					return nil, err
+				}
+				return services.CreateTodo(p.Context, todo)
+			},
+		},
+		"updateTodo": &graphql.Field{
+			Type: graphql.NewNonNull(todoType),
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(todoInputType)},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				todo, err := todoFromInput(p.Args["input"])
+				if err != nil {
+					return nil, err
+				}
+				todo.ID = p.Args["id"].(int)
+				return services.UpdateTodoByID(p.Context, strconv.Itoa(todo.ID), todo)
+			},
+		},
+		"deleteTodo": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "Moves a todo to the trash",
+			Args:        idArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if err := services.DeleteTodoByID(p.Context, strconv.Itoa(p.Args["id"].(int))); err != nil {
+					return nil, err
+				}
+				return true, nil
+			},
+		},
+		"completeTodo": &graphql.Field{
+			Type: graphql.NewNonNull(todoType),
+			Args: idArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return services.CompleteTodo(p.Context, strconv.Itoa(p.Args["id"].(int)))
+			},
+		},
+	},
+})
+
+func mustSchema() graphql.Schema {
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+	if err != nil {
+		panic(fmt.Sprintf("gql: invalid schema: %v", err))
+	}
+	return s
+}
+
+func resolveTodos(p graphql.ResolveParams) (interface{}, error) {
+	page, limit := p.Args["page"].(int), p.Args["limit"].(int)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	userID, ok := services.ActorFromContext(p.Context)
+	if !ok {
+		return nil, errors.New("not authenticated")
+	}
+	filter := services.TodoFilter{OwnerID: userID}
+	filter.Status, _ = p.Args["status"].(string)
+	filter.Search, _ = p.Args["search"].(string)
+
+	var err error
+	if from, ok := p.Args["dueFrom"].(string); ok {
+		if filter.DueFrom, err = time.Parse("2006-01-02", from); err != nil {
+			return nil, fmt.Errorf("invalid dueFrom %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to, ok := p.Args["dueTo"].(string); ok {
+		if filter.DueTo, err = time.Parse("2006-01-02", to); err != nil {
+			return nil, fmt.Errorf("invalid dueTo %q, expected YYYY-MM-DD", to)
+		}
+	}
+
+	return services.QueryTodos(p.Context, filter, page, limit)
+}
+
+// sourceTodo returns the todo a field is resolved on; lists hold values while single todos are pointers
+func sourceTodo(p graphql.ResolveParams) *models.TodoList {
+	switch todo := p.Source.(type) {
+	case *models.TodoList:
+		return todo
+	case models.TodoList:
+		return &todo
+	default:
+		panic(fmt.Sprintf("gql: unexpected todo source %T", p.Source))
+	}
+}
+
+func todoFromInput(value interface{}) (*models.TodoList, error) {
+	input := value.(map[string]interface{})
+
+	todo := &models.TodoList{
+		Title:       input["title"].(string),
+		Description: input["description"].(string),
+		Status:      input["status"].(string),
+	}
+	todo.Priority, _ = input["priority"].(string)
+	todo.Recurrence, _ = input["recurrence"].(string)
+	todo.AutoComplete, _ = input["autoComplete"].(bool)
+	if parentID, ok := input["parentId"].(int); ok {
+		todo.ParentID = sql.NullInt64{Int64: int64(parentID), Valid: true}
+	}
+	if dueDate, ok := input["dueDate"].(string); ok {
+		due, err := time.Parse("2006-01-02", dueDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dueDate %q, expected YYYY-MM-DD", dueDate)
+		}
+		todo.DueDate = sql.NullTime{Time: due, Valid: true}
+	}
+	return todo, nil
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"time"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func GetTodoHistoryHandler(c *fiber.Ctx) error {
+	page, limit := parsePagination(c)
+
+	history, err := services.GetTodoHistory(c.UserContext(), c.Params("id"), page, limit)
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get todo history", nil, err.Error())
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(history)
+}
+
+func GetAuditEventsHandler(c *fiber.Ctx) error {
+	page, limit := parsePagination(c)
+
+	filter := services.AuditFilter{
+		TodoID:    c.Query("todo_id"),
+		UserID:    c.Query("user_id"),
+		Operation: c.Query("operation"),
+	}
+
+	var err error
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid from timestamp, expected RFC 3339", nil, err.Error())
+			return nil
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid to timestamp, expected RFC 3339", nil, err.Error())
+			return nil
+		}
+	}
+
+	events, err := services.QueryAuditEvents(c.UserContext(), filter, page, limit)
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get audit events", nil, err.Error())
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(events)
+}
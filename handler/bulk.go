@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func BulkTodosHandler(c *fiber.Ctx) error {
+	var req services.BulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	results, err := services.ExecuteBulk(requestContext(c), req)
+	switch {
+	case errors.Is(err, services.ErrInvalidBulk):
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid bulk request", nil, err.Error())
+		return nil
+	case errors.Is(err, services.ErrBulkRolledBack):
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Bulk operation rolled back", results, err.Error())
+		return nil
+	case err != nil:
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to execute bulk operation", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Bulk operation completed", results, nil)
+	return nil
+}
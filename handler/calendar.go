@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func CreateCalendarTokenHandler(c *fiber.Ctx) error {
+	token, err := services.CreateCalendarToken(c.UserContext(), c.Locals("userId").(uint))
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to create calendar feed", nil, err.Error())
+		return err
+	}
+
+	feed := fiber.Map{
+		"token": token,
+		"url":   c.BaseURL() + "/api/v1/calendar/" + token + "/todos.ics",
+	}
+	helper.RespondJSON(c, fiber.StatusCreated, "Calendar feed created successfully", feed, nil)
+	return nil
+}
+
+func DeleteCalendarTokenHandler(c *fiber.Ctx) error {
+	err := services.DeleteCalendarToken(c.UserContext(), c.Locals("userId").(uint))
+	if errors.Is(err, services.ErrCalendarFeedNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Calendar feed not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete calendar feed", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Calendar feed deleted successfully", nil, nil)
+	return nil
+}
+
+// CalendarFeedHandler serves the iCalendar feed of the user owning the secret token in the URL.
+// Calendar apps cannot send a bearer token, so the URL itself is the credential.
+func CalendarFeedHandler(c *fiber.Ctx) error {
+	feed, err := services.RenderCalendarFeed(c.UserContext(), c.Params("token"), c.QueryBool("events"))
+	if errors.Is(err, services.ErrCalendarFeedNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Calendar feed not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to render calendar feed", nil, err.Error())
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.Status(fiber.StatusOK).Send(feed)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"todolist/docs"
+	"todolist/helper"
+)
+
+func OpenAPIHandler(c *fiber.Ctx) error {
+	spec, err := docs.Spec()
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to build OpenAPI document", nil, err.Error())
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Send(spec)
+}
+
+func DocsHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	c.Set(fiber.HeaderContentSecurityPolicy, docs.PageCSP)
+	return c.Send(docs.Page)
+}
+
+// DocsAssetHandler serves the Swagger UI files loaded by the documentation page
+func DocsAssetHandler(c *fiber.Ctx) error {
+	content, contentType, ok := docs.Asset(c.Params("file"))
+	if !ok {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Asset not found", nil, nil)
+		return nil
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(content)
+}
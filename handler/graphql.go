@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"todolist/gql"
+	"todolist/helper"
+)
+
+// GraphQLHandler executes a GraphQL query or mutation. As the GraphQL convention goes, field errors are
+// reported in the errors of the result with a 200 status rather than as an HTTP error.
+func GraphQLHandler(c *fiber.Ctx) error {
+	var req gql.Request
+	if err := c.BodyParser(&req); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+	if req.Query == "" {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "A query is required", nil, nil)
+		return nil
+	}
+
+	return c.Status(fiber.StatusOK).JSON(gql.Execute(requestContext(c), req))
+}
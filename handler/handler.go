@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"strconv"
 	"todolist/helper"
@@ -16,7 +17,7 @@ func CreateUserHandler(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	data, err := services.CreateUser(user)
+	data, err := services.CreateUser(ctx.UserContext(), user)
 	if err != nil {
 		helper.RespondJSON(ctx, fiber.StatusInternalServerError, "failed to create user", nil, err.Error())
 		return err
@@ -26,7 +27,8 @@ func CreateUserHandler(ctx *fiber.Ctx) error {
 	return nil
 }
 
-func GetAllTodosHandler(c *fiber.Ctx) error {
+// parsePagination reads the page and limit query parameters, falling back to the first page of 10
+func parsePagination(c *fiber.Ctx) (int, int) {
 	page, err := strconv.Atoi(c.Query("page", "1"))
 	if err != nil || page < 1 {
 		page = 1
@@ -37,7 +39,22 @@ func GetAllTodosHandler(c *fiber.Ctx) error {
 		limit = 10
 	}
 
-	paginatedTodos, err := services.GetAllTodos(c.Context(), page, limit)
+	return page, limit
+}
+
+// requestContext returns the context of the request, carrying the authenticated user as the actor of any change
+func requestContext(c *fiber.Ctx) context.Context {
+	ctx := c.UserContext()
+	if userId, ok := c.Locals("userId").(uint); ok {
+		ctx = services.WithActor(ctx, userId)
+	}
+	return ctx
+}
+
+func GetAllTodosHandler(c *fiber.Ctx) error {
+	page, limit := parsePagination(c)
+
+	paginatedTodos, err := services.GetAllTodos(c.UserContext(), page, limit)
 	if err != nil {
 		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get todos", nil, err.Error())
 		return err
@@ -48,12 +65,28 @@ func GetAllTodosHandler(c *fiber.Ctx) error {
 
 func GetTodoByIDHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
-	todo, err := services.GetTodoByID(context.Background(), id)
+	todo, err := services.GetTodoByID(c.UserContext(), id)
 	if err != nil {
 		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get todo", nil, err.Error())
 		return err
 	}
-	return c.JSON(fiber.Map{"todo": todo})
+	if todo == nil {
+		return c.JSON(fiber.Map{"todo": todo})
+	}
+
+	progress, err := services.GetTodoProgress(c.UserContext(), id)
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get todo progress", nil, err.Error())
+		return err
+	}
+
+	checklist, err := services.GetChecklist(c.UserContext(), id)
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get checklist", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"todo": todo, "progress": progress, "checklist": checklist})
 }
 
 func CreateTodoHandler(c *fiber.Ctx) error {
@@ -63,8 +96,11 @@ func CreateTodoHandler(c *fiber.Ctx) error {
 		return err
 	}
 
-	createdTodo, err := services.CreateTodo(&todo)
-	if err != nil {
+	createdTodo, err := services.CreateTodo(requestContext(c), &todo)
+	if errors.Is(err, services.ErrInvalidParent) {
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Invalid parent todo", nil, err.Error())
+		return nil
+	} else if err != nil {
 		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to create todo", nil, err.Error())
 		return err
 	}
@@ -81,8 +117,11 @@ func UpdateTodoHandler(c *fiber.Ctx) error {
 		return err
 	}
 
-	updatedTodo, err := services.UpdateTodoByID(context.Background(), id, &todo)
-	if err != nil {
+	updatedTodo, err := services.UpdateTodoByID(requestContext(c), id, &todo)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if err != nil {
 		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to update todo", nil, err.Error())
 		return err
 	}
@@ -93,12 +132,33 @@ func UpdateTodoHandler(c *fiber.Ctx) error {
 
 func DeleteTodoHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := services.DeleteTodoByID(context.Background(), id)
-	if err != nil {
+	err := services.DeleteTodoByID(requestContext(c), id)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if err != nil {
 		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete todo", nil, err.Error())
 		return err
 	}
 
-	helper.RespondJSON(c, fiber.StatusOK, "Todo deleted successfully", nil, nil)
+	helper.RespondJSON(c, fiber.StatusOK, "Todo moved to trash", nil, nil)
 	return nil
 }
+
+func GetUpcomingOccurrencesHandler(c *fiber.Ctx) error {
+	count, err := strconv.Atoi(c.Query("count", "5"))
+	if err != nil || count < 1 {
+		count = 5
+	}
+
+	occurrences, err := services.GetUpcomingOccurrences(c.UserContext(), c.Params("id"), count)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get upcoming occurrences", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"occurrences": occurrences})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func GetRemindersHandler(c *fiber.Ctx) error {
+	reminders, err := services.GetReminders(c.UserContext(), c.Params("id"))
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get reminders", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"reminders": reminders})
+}
+
+func CreateReminderHandler(c *fiber.Ctx) error {
+	var input services.ReminderInput
+	if err := c.BodyParser(&input); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	reminder, err := services.CreateReminder(c.UserContext(), c.Params("id"), input)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if errors.Is(err, services.ErrForbiddenDestination) {
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Reminder target is not allowed", nil, err.Error())
+		return nil
+	} else if errors.Is(err, services.ErrChannelNotConfigured) {
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Reminder channel is not available", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to create reminder", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusCreated, "Reminder created successfully", reminder, nil)
+	return nil
+}
+
+func DeleteReminderHandler(c *fiber.Ctx) error {
+	err := services.DeleteReminder(c.UserContext(), c.Params("id"), c.Params("reminderId"))
+	if errors.Is(err, services.ErrReminderNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Reminder not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete reminder", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Reminder deleted successfully", nil, nil)
+	return nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"time"
+	"todolist/helper"
+	"todolist/services"
+)
+
+// streamKeepAlive is how often a comment is sent on idle streams so proxies keep the connection open
+const streamKeepAlive = 15 * time.Second
+
+// StreamTodoEventsHandler pushes the events about the todos of the authenticated user as Server-Sent Events. Clients resuming after a disconnect
+// send the ID of the last event they received in the Last-Event-ID header (or last_event_id query parameter)
+// and first receive the events they missed.
+func StreamTodoEventsHandler(c *fiber.Ctx) error {
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	userId := c.Locals("userId").(uint)
+
+	// Subscribe before replaying so no event is lost between the two
+	events, unsubscribe := services.SubscribeTodoEvents(userId)
+
+	var missed []services.TodoEvent
+	if lastEventID != "" {
+		var err error
+		missed, err = services.ReplayTodoEvents(c.UserContext(), userId, lastEventID)
+		if err != nil {
+			unsubscribe()
+			helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to replay todo events", nil, err.Error())
+			return err
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		for _, event := range missed {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			lastEventID = event.ID
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					// Too slow to keep up; the client reconnects and resumes from its last event
+					return
+				}
+				if lastEventID != "" && services.CompareEventIDs(event.ID, lastEventID) <= 0 {
+					continue
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				lastEventID = event.ID
+			case <-keepAlive.C:
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+
+			// A failed flush means the client went away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeEvent(w *bufio.Writer, event services.TodoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/models"
+	"todolist/services"
+)
+
+type orderInput struct {
+	IDs []int `json:"ids"`
+}
+
+type checklistItemInput struct {
+	Content string `json:"content"`
+}
+
+func GetSubtasksHandler(c *fiber.Ctx) error {
+	subtasks, err := services.GetSubtasks(c.UserContext(), c.Params("id"))
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get subtasks", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"subtasks": subtasks})
+}
+
+func CreateSubtaskHandler(c *fiber.Ctx) error {
+	var todo models.TodoList
+	if err := c.BodyParser(&todo); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	subtask, err := services.CreateSubtask(requestContext(c), c.Params("id"), &todo)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to create subtask", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusCreated, "Subtask created successfully", subtask, nil)
+	return nil
+}
+
+func ReorderSubtasksHandler(c *fiber.Ctx) error {
+	var input orderInput
+	if err := c.BodyParser(&input); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	err := services.ReorderSubtasks(c.UserContext(), c.Params("id"), input.IDs)
+	if errors.Is(err, services.ErrInvalidOrder) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid subtask order", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to reorder subtasks", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Subtasks reordered successfully", nil, nil)
+	return nil
+}
+
+func GetChecklistHandler(c *fiber.Ctx) error {
+	items, err := services.GetChecklist(c.UserContext(), c.Params("id"))
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get checklist", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"checklist": items})
+}
+
+func AddChecklistItemHandler(c *fiber.Ctx) error {
+	var input checklistItemInput
+	if err := c.BodyParser(&input); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	item, err := services.AddChecklistItem(c.UserContext(), c.Params("id"), input.Content)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to add checklist item", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusCreated, "Checklist item added successfully", item, nil)
+	return nil
+}
+
+func ToggleChecklistItemHandler(c *fiber.Ctx) error {
+	item, err := services.ToggleChecklistItem(c.UserContext(), c.Params("id"), c.Params("itemId"))
+	if errors.Is(err, services.ErrChecklistItemNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Checklist item not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to toggle checklist item", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Checklist item toggled successfully", item, nil)
+	return nil
+}
+
+func DeleteChecklistItemHandler(c *fiber.Ctx) error {
+	err := services.DeleteChecklistItem(c.UserContext(), c.Params("id"), c.Params("itemId"))
+	if errors.Is(err, services.ErrChecklistItemNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Checklist item not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete checklist item", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Checklist item deleted successfully", nil, nil)
+	return nil
+}
+
+func ReorderChecklistHandler(c *fiber.Ctx) error {
+	var input orderInput
+	if err := c.BodyParser(&input); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	err := services.ReorderChecklist(c.UserContext(), c.Params("id"), input.IDs)
+	if errors.Is(err, services.ErrInvalidOrder) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid checklist order", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to reorder checklist", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Checklist reordered successfully", nil, nil)
+	return nil
+}
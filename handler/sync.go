@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func GetChangesHandler(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", services.DefaultSyncLimit)
+
+	changes, err := services.GetChanges(c.UserContext(), c.Locals("userId").(uint), c.Query("since"), limit)
+	if errors.Is(err, services.ErrInvalidSyncToken) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid sync token", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get changes", nil, err.Error())
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(changes)
+}
+
+func PushChangesHandler(c *fiber.Ctx) error {
+	var req services.SyncPushRequest
+	if err := c.BodyParser(&req); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	results, err := services.PushChanges(requestContext(c), req)
+	if errors.Is(err, services.ErrInvalidSync) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid sync request", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to push changes", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Changes pushed", results, nil)
+	return nil
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"strings"
+	"time"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func ExportTodosHandler(c *fiber.Ctx) error {
+	format := c.Query("format", services.FormatCSV)
+	if !services.IsExportFormat(format) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid export format, expected csv, json, todotxt or markdown", nil, nil)
+		return nil
+	}
+
+	filter := services.TodoFilter{
+		OwnerID: c.Locals("userId").(uint),
+		Status:  c.Query("status"),
+		Search:  c.Query("q"),
+	}
+
+	var err error
+	if from := c.Query("due_from"); from != "" {
+		if filter.DueFrom, err = time.Parse("2006-01-02", from); err != nil {
+			helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid due_from date, expected YYYY-MM-DD", nil, err.Error())
+			return nil
+		}
+	}
+	if to := c.Query("due_to"); to != "" {
+		if filter.DueTo, err = time.Parse("2006-01-02", to); err != nil {
+			helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid due_to date, expected YYYY-MM-DD", nil, err.Error())
+			return nil
+		}
+	}
+
+	contentType, extension := "text/csv; charset=utf-8", "csv"
+	switch format {
+	case services.FormatJSON:
+		contentType, extension = fiber.MIMEApplicationJSONCharsetUTF8, "json"
+	case services.FormatTodoTxt:
+		contentType, extension = fiber.MIMETextPlainCharsetUTF8, "txt"
+	case services.FormatMarkdown:
+		contentType, extension = "text/markdown; charset=utf-8", "md"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="todos.`+extension+`"`)
+
+	// The request context is released once the handler returns, so the export runs on its own
+	ctx := context.WithoutCancel(c.UserContext())
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := services.ExportTodos(ctx, w, format, filter); err != nil {
+			slog.ErrorContext(ctx, "Failed to export todos", "error", err)
+		}
+		if err := w.Flush(); err != nil {
+			slog.ErrorContext(ctx, "Failed to flush todo export", "error", err)
+		}
+	})
+
+	return nil
+}
+
+func ImportTodosHandler(c *fiber.Ctx) error {
+	opts := services.ImportOptions{
+		Format: c.Query("format"),
+		DryRun: c.QueryBool("dry_run"),
+	}
+	if opts.Format == "" {
+		switch contentType := string(c.Request().Header.ContentType()); {
+		case strings.Contains(contentType, "csv"):
+			opts.Format = services.FormatCSV
+		case strings.Contains(contentType, "json"):
+			opts.Format = services.FormatJSON
+		case strings.Contains(contentType, "calendar"):
+			opts.Format = services.FormatICS
+		case strings.Contains(contentType, "markdown"):
+			opts.Format = services.FormatMarkdown
+		case strings.Contains(contentType, "text/plain"):
+			opts.Format = services.FormatTodoTxt
+		}
+	}
+
+	// Mappings are given as map=Source Column:field,Other:field
+	if mapping := c.Query("map"); mapping != "" {
+		opts.Mapping = make(map[string]string)
+		for _, pair := range strings.Split(mapping, ",") {
+			column, field, ok := strings.Cut(pair, ":")
+			if !ok {
+				helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid column mapping, expected column:field pairs", nil, pair)
+				return nil
+			}
+			opts.Mapping[strings.TrimSpace(column)] = strings.TrimSpace(field)
+		}
+	}
+
+	report, err := services.ImportTodos(requestContext(c), c.Body(), opts)
+	if errors.Is(err, services.ErrInvalidImport) || errors.Is(err, services.ErrInvalidParent) {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Invalid import", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to import todos", nil, err.Error())
+		return err
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Import has invalid rows, nothing was imported", report, nil)
+	case report.DryRun:
+		helper.RespondJSON(c, fiber.StatusOK, "Dry run succeeded, nothing was imported", report, nil)
+	default:
+		helper.RespondJSON(c, fiber.StatusCreated, "Todos imported successfully", report, nil)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func GetTrashHandler(c *fiber.Ctx) error {
+	page, limit := parsePagination(c)
+
+	paginatedTodos, err := services.GetTrashedTodos(c.UserContext(), page, limit)
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get trash", nil, err.Error())
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(paginatedTodos)
+}
+
+func RestoreTodoHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := services.RestoreTodoByID(requestContext(c), id)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found in trash", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to restore todo", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Todo restored successfully", nil, nil)
+	return nil
+}
+
+func PurgeTodoHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := services.PurgeTodoByID(requestContext(c), id)
+	if errors.Is(err, services.ErrTodoNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found in trash", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete todo", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Todo permanently deleted", nil, nil)
+	return nil
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+	"todolist/services"
+)
+
+func ListWebhooksHandler(c *fiber.Ctx) error {
+	webhooks, err := services.ListWebhooks(c.UserContext(), c.Locals("userId").(uint))
+	if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get webhooks", nil, err.Error())
+		return err
+	}
+
+	return c.JSON(fiber.Map{"webhooks": webhooks})
+}
+
+func CreateWebhookHandler(c *fiber.Ctx) error {
+	var input services.WebhookInput
+	if err := c.BodyParser(&input); err != nil {
+		helper.RespondJSON(c, fiber.StatusBadRequest, "Failed to parse request body", nil, err.Error())
+		return err
+	}
+
+	webhook, err := services.CreateWebhook(c.UserContext(), c.Locals("userId").(uint), input)
+	if errors.Is(err, services.ErrForbiddenDestination) {
+		helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Webhook URL is not allowed", nil, err.Error())
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to create webhook", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusCreated, "Webhook created successfully", webhook, nil)
+	return nil
+}
+
+func DeleteWebhookHandler(c *fiber.Ctx) error {
+	err := services.DeleteWebhook(c.UserContext(), c.Locals("userId").(uint), c.Params("id"))
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Webhook not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to delete webhook", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusOK, "Webhook deleted successfully", nil, nil)
+	return nil
+}
+
+func GetWebhookDeliveriesHandler(c *fiber.Ctx) error {
+	page, limit := parsePagination(c)
+
+	deliveries, err := services.GetWebhookDeliveries(c.UserContext(), c.Locals("userId").(uint), c.Params("id"), page, limit)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Webhook not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to get webhook deliveries", nil, err.Error())
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(deliveries)
+}
+
+func RedeliverWebhookHandler(c *fiber.Ctx) error {
+	id, err := services.RedeliverWebhook(c.UserContext(), c.Locals("userId").(uint), c.Params("id"), c.Params("deliveryId"))
+	if errors.Is(err, services.ErrDeliveryNotFound) {
+		helper.RespondJSON(c, fiber.StatusNotFound, "Webhook delivery not found", nil, nil)
+		return nil
+	} else if err != nil {
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to redeliver webhook", nil, err.Error())
+		return err
+	}
+
+	helper.RespondJSON(c, fiber.StatusAccepted, "Webhook redelivery queued", fiber.Map{"delivery_id": id}, nil)
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package health serves the liveness and readiness probes of the application. Readiness runs the checks
+// registered for each dependency, and turns negative for good once the server starts shutting down.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Statuses reported by the probes
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+)
+
+// DefaultTimeout bounds each readiness check, so a hanging dependency cannot stall the probe
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable, returning an error when it is not
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of the check of a single dependency
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Error is logged rather than served, as it may describe the internals of the dependency
+	Error string `json:"-"`
+}
+
+// Report is the body of a readiness response
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+var (
+	mu       sync.RWMutex
+	checks   = map[string]Check{}
+	timeout  = DefaultTimeout
+	draining atomic.Bool
+)
+
+// Register adds the readiness check of the dependency called name, replacing any previous one
+func Register(name string, check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks[name] = check
+}
+
+// SetTimeout changes how long each readiness check may take
+func SetTimeout(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	timeout = d
+}
+
+// Drain marks the server as shutting down: readiness fails from now on so load balancers stop routing
+// new requests to it, while the requests in flight complete
+func Drain() {
+	draining.Store(true)
+}
+
+// Ready runs every registered check concurrently and reports whether all dependencies are up
+func Ready(ctx context.Context) Report {
+	mu.RLock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pending := make([]Check, len(names))
+	for i, name := range names {
+		pending[i] = checks[name]
+	}
+	limit := timeout
+	mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, check := range pending {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check, limit)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+	if draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check, limit time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Status: StatusUp, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler answers the liveness probe, which succeeds as long as the process serves requests
+func LiveHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": StatusUp})
+	}
+}
+
+// ReadyHandler answers the readiness probe with the report of every check, failing with 503 Service
+// Unavailable when a dependency is down or the server is draining
+func ReadyHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := Ready(c.UserContext())
+		for name, result := range report.Checks {
+			if result.Status != StatusUp {
+				slog.Warn("Readiness check failed", "check", name, "error", result.Error)
+			}
+		}
+		status := fiber.StatusOK
+		if report.Status != StatusReady {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	}
+}
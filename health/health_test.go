@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reset restores the package state once the test is done
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		checks = map[string]Check{}
+		timeout = DefaultTimeout
+		draining.Store(false)
+	})
+}
+
+func probe(t *testing.T, target string) (int, Report) {
+	app := fiber.New()
+	app.Get("/health/live", LiveHandler())
+	app.Get("/health/ready", ReadyHandler())
+
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+	require.NoError(t, err)
+	var report Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return resp.StatusCode, report
+}
+
+func TestReadyReportsEveryDependency(t *testing.T) {
+	reset(t)
+	Register("oracle", func(ctx context.Context) error { return nil })
+	Register("redis", func(ctx context.Context) error { return nil })
+
+	status, report := probe(t, "/health/ready")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, StatusReady, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["oracle"].Status)
+	assert.Equal(t, StatusUp, report.Checks["redis"].Status)
+
+	Register("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	status, report = probe(t, "/health/ready")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["oracle"].Status)
+	assert.Equal(t, CheckResult{Status: StatusDown, LatencyMS: report.Checks["redis"].LatencyMS}, report.Checks["redis"],
+		"the error is logged, not served")
+}
+
+func TestReadyTimesOutHangingChecks(t *testing.T) {
+	reset(t)
+	SetTimeout(20 * time.Millisecond)
+	Register("oracle", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := Ready(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["oracle"].Error)
+	assert.GreaterOrEqual(t, report.Checks["oracle"].LatencyMS, float64(20))
+}
+
+func TestDrainFailsReadinessButNotLiveness(t *testing.T) {
+	reset(t)
+	Register("oracle", func(ctx context.Context) error { return nil })
+	Drain()
+
+	status, report := probe(t, "/health/ready")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.Equal(t, StatusDraining, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["oracle"].Status)
+
+	status, report = probe(t, "/health/live")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, StatusUp, report.Status)
+}
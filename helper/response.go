@@ -1,12 +1,19 @@
 package helper
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type ResponseData struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Task    interface{} `json:"task"`
 	Error   interface{} `json:"error"`
+	// TraceID identifies the trace of a failed request, to look it up in the tracing backend
+	TraceID string `json:"trace_id,omitempty"`
+	// RequestID identifies a failed request, to look up its log lines
+	RequestID string `json:"request_id,omitempty"`
 }
 type ErrorField struct {
 	ID      string `json:"id"`
@@ -22,6 +29,12 @@ func RespondJSON(ctx *fiber.Ctx, status int, message string, payload interface{}
 		Task:    payload,
 		Error:   errors,
 	}
+	if !res.Success {
+		if spanContext := trace.SpanContextFromContext(ctx.UserContext()); spanContext.HasTraceID() {
+			res.TraceID = spanContext.TraceID().String()
+		}
+		res.RequestID = ctx.GetRespHeader(fiber.HeaderXRequestID)
+	}
 
 	// Send the JSON response and ignore the error since the response is the primary concern
 	if err := ctx.Status(status).JSON(res); err != nil {
@@ -0,0 +1,169 @@
+// Package logging configures the structured slog logger used across the application, with request IDs,
+// trace IDs, redaction of secrets and rotating log files
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"todolist/helper"
+)
+
+// Formats selected with LOG_FORMAT
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the value of every attribute that holds a secret
+const Redacted = "[REDACTED]"
+
+// Config describes where and how log records are written
+type Config struct {
+	// Level is the minimum level of the records written
+	Level slog.Level
+	// Format is FormatJSON or FormatText
+	Format string
+	// Output is "stdout", "stderr" or the path of a log file
+	Output string
+	// MaxSize rotates the log file once it grows past this many bytes, 0 disables size-based rotation
+	MaxSize int64
+	// RotateEvery rotates the log file once it has been written to for this long, 0 disables time-based rotation
+	RotateEvery time.Duration
+	// MaxBackups is the number of rotated files kept next to the log file, 0 keeps all of them
+	MaxBackups int
+}
+
+// ConfigFromEnv reads the logging configuration from LOG_LEVEL, LOG_FORMAT, LOG_OUTPUT, LOG_MAX_SIZE_MB,
+// LOG_ROTATE_EVERY and LOG_MAX_BACKUPS
+func ConfigFromEnv() (Config, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(helper.GetEnv("LOG_LEVEL", "info"))); err != nil {
+		return Config{}, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+
+	return Config{
+		Level:       level,
+		Format:      helper.GetEnv("LOG_FORMAT", FormatJSON),
+		Output:      helper.GetEnv("LOG_OUTPUT", "stdout"),
+		MaxSize:     int64(helper.GetEnvInt("LOG_MAX_SIZE_MB", 100)) << 20,
+		RotateEvery: helper.GetEnvDuration("LOG_ROTATE_EVERY", 24*time.Hour),
+		MaxBackups:  helper.GetEnvInt("LOG_MAX_BACKUPS", 7),
+	}, nil
+}
+
+// New creates a logger for cfg. The returned closer releases the log file, if any.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	var out io.Writer
+	var closer io.Closer = nopCloser{}
+	switch cfg.Output {
+	case "", "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := OpenRotatingFile(cfg.Output, cfg.MaxSize, cfg.RotateEvery, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = file, file
+	}
+
+	handler, err := NewHandler(out, cfg)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return slog.New(handler), closer, nil
+}
+
+// NewHandler creates a handler writing records to out in the format of cfg. It redacts secrets and adds the
+// request and trace IDs found in the context of each record.
+func NewHandler(out io.Writer, cfg Config) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(out, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(out, opts)
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT %q", cfg.Format)
+	}
+	return contextHandler{handler}, nil
+}
+
+// Setup installs the logger configured by the environment as the default slog logger, which the standard
+// log package writes through as well. The returned closer releases the log file, if any.
+func Setup() (io.Closer, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	logger, closer, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// contextHandler adds the request ID and trace ID of the context a record is logged with
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// sensitiveKeys are the attribute key fragments whose values are never written to the log
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "api_key", "apikey"}
+
+// redact hides the values of attributes holding credentials and the secrets embedded in logged paths
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, Redacted)
+		}
+	}
+	if key == "path" && attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, RedactPath(attr.Value.String()))
+	}
+	return attr
+}
+
+// secretPathSegments match the path segments that act as credentials, like the calendar feed token
+var secretPathSegments = regexp.MustCompile(`(/calendar/)[^/]+(/todos\.ics)`)
+
+// RedactPath hides the credentials embedded in a request path
+func RedactPath(path string) string {
+	return secretPathSegments.ReplaceAllString(path, "${1}"+Redacted+"${2}")
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureDefault installs a JSON logger writing to a buffer as the default logger for the test
+func captureDefault(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	handler, err := NewHandler(&out, Config{Level: slog.LevelDebug})
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &out
+}
+
+func decodeLines(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestHandlerRedactsSecrets(t *testing.T) {
+	out := captureDefault(t)
+
+	slog.Info("login", "username", "alice", "password", "hunter2", "Authorization", "Bearer abc",
+		"path", "/api/v1/calendar/s3cr3t/todos.ics")
+
+	record := decodeLines(t, out)[0]
+	assert.Equal(t, "alice", record["username"])
+	assert.Equal(t, Redacted, record["password"])
+	assert.Equal(t, Redacted, record["Authorization"])
+	assert.Equal(t, "/api/v1/calendar/[REDACTED]/todos.ics", record["path"])
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "s3cr3t")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	out := captureDefault(t)
+
+	app := fiber.New()
+	app.Use(RequestIDMiddleware())
+	app.Use(AccessLog())
+	app.Get("/todo/:id", func(c *fiber.Ctx) error {
+		slog.InfoContext(c.UserContext(), "handling")
+		return c.SendString("ok")
+	})
+	app.Get("/fail", func(c *fiber.Ctx) error { return fiber.NewError(fiber.StatusBadGateway, "upstream") })
+
+	req := httptest.NewRequest("GET", "/todo/1", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "client-id-1")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, "client-id-1", resp.Header.Get(fiber.HeaderXRequestID))
+
+	records := decodeLines(t, out)
+	require.Len(t, records, 2)
+	assert.Equal(t, "handling", records[0]["msg"])
+	assert.Equal(t, "client-id-1", records[0]["request_id"])
+	assert.Equal(t, "request", records[1]["msg"])
+	assert.Equal(t, "INFO", records[1]["level"])
+	assert.Equal(t, "/todo/:id", records[1]["route"])
+	assert.EqualValues(t, 200, records[1]["status"])
+	assert.Equal(t, "client-id-1", records[1]["request_id"])
+
+	// An invalid ID is replaced by a generated one, and server errors are logged at the error level
+	out.Reset()
+	req = httptest.NewRequest("GET", "/fail", nil)
+	req.Header.Set(fiber.HeaderXRequestID, strings.Repeat("x", maxRequestIDLength+1))
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	generated := resp.Header.Get(fiber.HeaderXRequestID)
+	assert.Len(t, generated, 36)
+
+	record := decodeLines(t, out)[0]
+	assert.Equal(t, "ERROR", record["level"])
+	assert.EqualValues(t, fiber.StatusBadGateway, record["status"])
+	assert.Equal(t, generated, record["request_id"])
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	file, err := OpenRotatingFile(path, 10, 0, 2)
+	require.NoError(t, err)
+	defer file.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := file.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth\n", string(current))
+
+	// Every line overflowed the 10 byte limit, so each was rotated out and only the two newest backups remain
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	newest, err := os.ReadFile(backups[1])
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(newest))
+}
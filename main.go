@@ -1,34 +1,162 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"todolist/database"
+	"todolist/health"
+	"todolist/helper"
+	"todolist/logging"
+	"todolist/metrics"
 	"todolist/router"
+	"todolist/rpc"
+	"todolist/services"
+	"todolist/tlsconfig"
+	"todolist/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	_, rdb, err := database.InitDatabase()
+	logCloser, err := logging.Setup()
+	if err != nil {
+		fatal("Failed to initialize logging", err)
+	}
+	defer logCloser.Close()
+
+	// Export spans for requests, queries and Redis calls to the configured collector
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	db, rdb, err := database.InitDatabase()
 	if err != nil {
-		log.Fatal("Failed to initialize database: ", err)
+		fatal("Failed to initialize database", err)
+	}
+	if err := metrics.RegisterDB(db, "oracle"); err != nil {
+		fatal("Failed to register database metrics", err)
 	}
 
 	defer func() {
 		if err := rdb.Close(); err != nil {
-			log.Fatal("Failed to close database: ", err)
+			slog.Error("Failed to close database", "error", err)
 		}
 	}()
 
+	// Report the server as ready only while both stores answer
+	health.SetTimeout(helper.GetEnvDuration("HEALTH_CHECK_TIMEOUT", health.DefaultTimeout))
+	health.Register("oracle", db.PingContext)
+	health.Register("redis", func(ctx context.Context) error { return rdb.Ping(ctx).Err() })
+
+	// Background workers stop once the process is asked to terminate
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownTimeout := helper.GetEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second)
+
+	// Periodically empty todos that have been in the trash longer than the retention period
+	retention := helper.GetEnvDuration("TRASH_RETENTION", 30*24*time.Hour)
+	go services.StartTrashPurger(ctx, retention, time.Hour)
+
+	httpClient := services.NewOutboundClient(10 * time.Second)
+
+	// Deliver due-date reminders through the configured notification channels
+	services.RegisterNotifier(services.ChannelWebhook, &services.WebhookNotifier{Client: httpClient})
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		host, _, _ := net.SplitHostPort(smtpAddr)
+		services.RegisterNotifier(services.ChannelEmail, &services.EmailNotifier{
+			Addr:    smtpAddr,
+			From:    helper.GetEnv("SMTP_FROM", "todolist@localhost"),
+			Auth:    smtp.PlainAuth("", os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), host),
+			Timeout: helper.GetEnvDuration("SMTP_TIMEOUT", 30*time.Second),
+		})
+	}
+	go services.StartReminderScheduler(ctx, helper.GetEnvDuration("REMINDER_INTERVAL", 30*time.Second))
+
+	// Deliver queued todo events to registered webhooks
+	go services.StartWebhookDispatcher(ctx, httpClient, helper.GetEnvDuration("WEBHOOK_INTERVAL", 5*time.Second))
+
 	// Initialize router and start the server
-	app, logFile := router.Make() // Make function returns the app and the log file
-	defer logFile.Close()         // Close the log file when the application exits
+	app, err := router.Make()
+	if err != nil {
+		fatal("Failed to configure the server", err)
+	}
+
+	// Terminate TLS when a certificate is configured, picking up renewed certificates as they are written
+	tlsConfig, certReloader, err := tlsconfig.FromEnv()
+	if err != nil {
+		fatal("Failed to load the TLS certificate", err)
+	}
+	var grpcOptions []grpc.ServerOption
+	if tlsConfig != nil {
+		go certReloader.Watch(ctx, helper.GetEnvDuration("TLS_RELOAD_INTERVAL", time.Minute))
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 
 	// Start the server in a separate goroutine
 	go func() {
-		if err := app.Listen(":4000"); err != nil {
-			log.Fatalf("Failed to start the server: %v", err)
+		addr := helper.GetEnv("HTTP_ADDR", ":4000")
+		var err error
+		if tlsConfig != nil {
+			var listener net.Listener
+			if listener, err = tls.Listen("tcp", addr, tlsConfig); err == nil {
+				err = app.Listener(listener)
+			}
+		} else {
+			err = app.Listen(addr)
+		}
+		if err != nil {
+			fatal("Failed to start the server", err)
+		}
+	}()
+
+	// Serve the metrics on an internal port, out of reach of API clients
+	go func() {
+		if err := metrics.ListenAndServe(ctx, helper.GetEnv("METRICS_ADDR", "127.0.0.1:9090")); err != nil {
+			fatal("Failed to start the metrics server", err)
 		}
 	}()
 
-	// Gracefully handle shutdown
-	select {} // Block forever, server continues running
+	// Serve the gRPC API on its own port. It keeps serving through the drain delay below, like the HTTP server.
+	grpcCtx, stopGRPC := context.WithCancel(context.Background())
+	grpcStopped := make(chan struct{})
+	go func() {
+		defer close(grpcStopped)
+		if err := rpc.ListenAndServe(grpcCtx, helper.GetEnv("GRPC_ADDR", ":4001"), shutdownTimeout, grpcOptions...); err != nil {
+			fatal("Failed to start the gRPC server", err)
+		}
+	}()
+
+	// Gracefully handle shutdown: fail readiness first and give load balancers time to notice, then let the
+	// requests and calls in flight complete
+	<-ctx.Done()
+	slog.Info("Shutting down")
+	health.Drain()
+	time.Sleep(helper.GetEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second))
+	stopGRPC()
+	if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		slog.Error("Failed to shut down the server", "error", err)
+	}
+	<-grpcStopped
+}
+
+// fatal logs err and exits, for failures the server cannot run without
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,135 @@
+// Package metrics collects the Prometheus metrics of the service and serves them at /metrics on an internal
+// listener, apart from the public API
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "todolist"
+
+// Cache names and results counted by CacheLookup
+const (
+	CacheTodos = "todos"
+	CacheTodo  = "todo"
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+)
+
+// Registry holds the metrics of the service, along with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Redis cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts, by result (success or failure).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration, cacheLookups, logins,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics of db: open, in-use and idle connections and time spent waiting
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// CacheLookup counts a lookup in one of the Redis caches
+func CacheLookup(cache string, hit bool) {
+	result := CacheMiss
+	if hit {
+		result = CacheHit
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// Login counts a login attempt
+func Login(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(result).Inc()
+}
+
+// Middleware counts and times every request under the route it matched, such as /api/v1/todo/:id,
+// so that IDs in paths do not create a time series per todo
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		// Errors returned by handlers are turned into the response by the error handler after this middleware
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+				// Requests matching no route end at this middleware; count them together rather than by path
+				if status == fiber.StatusNotFound && route == "/" {
+					route = "unmatched"
+				}
+			}
+		}
+
+		labels := []string{c.Method(), route, strconv.Itoa(status)}
+		requests.WithLabelValues(labels...).Inc()
+		requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+// ListenAndServe serves the metrics at /metrics on addr until ctx is done. addr should only be reachable by
+// the scraper, as the metrics reveal the routes and traffic of the service.
+func ListenAndServe(ctx context.Context, addr string) error {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/metrics", Handler())
+
+	go func() {
+		<-ctx.Done()
+		_ = app.Shutdown()
+	}()
+	return app.Listen(addr)
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareLabelsRequestsByRoute(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/metrics", Handler())
+	app.Get("/todo/:id", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/fail", func(c *fiber.Ctx) error { return fiber.NewError(fiber.StatusBadRequest, "bad") })
+
+	for _, target := range []string{"/todo/1", "/todo/2", "/fail", "/missing/7"} {
+		_, err := app.Test(httptest.NewRequest("GET", target, nil))
+		require.NoError(t, err)
+	}
+	CacheLookup(CacheTodo, true)
+	Login(false)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	out := string(body)
+
+	assert.Contains(t, out, `todolist_http_requests_total{method="GET",route="/todo/:id",status="200"} 2`)
+	assert.Contains(t, out, `todolist_http_requests_total{method="GET",route="/fail",status="400"} 1`)
+	assert.Contains(t, out, `todolist_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, out, `todolist_http_request_duration_seconds_count{method="GET",route="/todo/:id",status="200"} 2`)
+	assert.Contains(t, out, `todolist_cache_lookups_total{cache="todo",result="hit"} 1`)
+	assert.Contains(t, out, `todolist_logins_total{result="failure"} 1`)
+	assert.Contains(t, out, "go_goroutines")
+}
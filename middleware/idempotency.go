@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"todolist/database"
+	"todolist/helper"
+)
+
+// HeaderIdempotencyKey names the header clients set to make retrying a POST request safe
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed is set on responses replayed from an earlier request with the same key
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockTimeout releases the key of a request whose server died before it responded. The server
+// handling a request keeps extending it, however long the handler runs.
+var idempotencyLockTimeout = time.Minute
+
+// idempotentResponse is stored under an idempotency key, first without a status while the request is
+// handled and then with its response
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to retry. The response to the
+// first request with a key is stored for ttl and replayed for later requests with the same key, URL,
+// Content-Type and body.
+// Reusing a key with another body is refused with 422 Unprocessable Entity, and a request whose key is
+// still being handled with 409 Conflict. Server errors and errors returned by the handler are not stored,
+// so the requests failing with one can be retried. Keys are scoped to the user set by Auth, so it must come
+// after Auth.
+func Idempotency(ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if c.Method() != fiber.MethodPost || key == "" || database.RedisClient == nil {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			helper.RespondJSON(c, fiber.StatusBadRequest, "Idempotency-Key is too long", nil, nil)
+			return nil
+		}
+
+		redisKey := "idempotency:ip:" + c.IP() + ":" + key
+		if userId, ok := c.Locals("userId").(uint); ok {
+			redisKey = "idempotency:user:" + strconv.FormatUint(uint64(userId), 10) + ":" + key
+		}
+		pending := idempotentResponse{Fingerprint: idempotencyFingerprint(c)}
+
+		stored, err := claimIdempotencyKey(c, redisKey, pending)
+		if err != nil {
+			slog.WarnContext(c.UserContext(), "Failed to check idempotency key", "error", err)
+			return c.Next()
+		}
+
+		switch {
+		case stored == nil:
+			return handleIdempotent(c, redisKey, pending, ttl)
+		case stored.Fingerprint != pending.Fingerprint:
+			helper.RespondJSON(c, fiber.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil, nil)
+			return nil
+		case stored.Status == 0:
+			helper.RespondJSON(c, fiber.StatusConflict, "A request with this Idempotency-Key is still being processed", nil, nil)
+			return nil
+		}
+
+		c.Set(HeaderIdempotentReplayed, "true")
+		if stored.ContentType != "" {
+			c.Set(fiber.HeaderContentType, stored.ContentType)
+		}
+		return c.Status(stored.Status).Send(stored.Body)
+	}
+}
+
+// idempotencyFingerprint identifies a request by its method, URL including the query string, Content-Type
+// and body
+func idempotencyFingerprint(c *fiber.Ctx) string {
+	request := c.Method() + " " + c.OriginalURL() + "\n" + c.Get(fiber.HeaderContentType) + "\n"
+	fingerprint := sha256.Sum256(append([]byte(request), c.Body()...))
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// claimIdempotencyKey stores pending under key unless a request already did, in which case it returns
+// what that request stored
+func claimIdempotencyKey(c *fiber.Ctx, key string, pending idempotentResponse) (*idempotentResponse, error) {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored entry may expire between both commands, in which case claiming it again succeeds
+	for attempt := 0; attempt < 2; attempt++ {
+		claimed, err := database.RedisClient.SetNX(c.UserContext(), key, data, idempotencyLockTimeout).Result()
+		if err != nil || claimed {
+			return nil, err
+		}
+
+		existing, err := database.RedisClient.Get(c.UserContext(), key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var stored idempotentResponse
+		if err := json.Unmarshal(existing, &stored); err != nil {
+			return nil, err
+		}
+		return &stored, nil
+	}
+	return nil, errors.New("idempotency key kept expiring")
+}
+
+// handleIdempotent runs the handler of a request that claimed its key, then stores its response, or
+// releases the key when it failed with a server error
+func handleIdempotent(c *fiber.Ctx, key string, pending idempotentResponse, ttl time.Duration) error {
+	release := holdIdempotencyKey(c.UserContext(), key)
+	err := c.Next()
+	release()
+
+	status := c.Response().StatusCode()
+	if err != nil || status >= fiber.StatusInternalServerError {
+		if delErr := database.RedisClient.Del(c.UserContext(), key).Err(); delErr != nil {
+			slog.WarnContext(c.UserContext(), "Failed to release idempotency key", "error", delErr)
+		}
+		return err
+	}
+
+	pending.Status = status
+	pending.ContentType = string(c.Response().Header.ContentType())
+	pending.Body = append([]byte(nil), c.Response().Body()...)
+	data, storeErr := json.Marshal(pending)
+	if storeErr == nil {
+		storeErr = database.RedisClient.Set(c.UserContext(), key, data, ttl).Err()
+	}
+	if storeErr != nil {
+		slog.WarnContext(c.UserContext(), "Failed to store idempotent response", "error", storeErr)
+	}
+	return nil
+}
+
+// holdIdempotencyKey extends the claim on key before it times out, until the returned function is called
+func holdIdempotencyKey(ctx context.Context, key string) func() {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyLockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := database.RedisClient.PExpire(ctx, key, idempotencyLockTimeout).Err(); err != nil {
+					slog.WarnContext(ctx, "Failed to extend idempotency key", "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postWithKey(t *testing.T, app *fiber.App, key, body string) (int, string, string) {
+	return postTo(t, app, "/todo", fiber.MIMEApplicationJSON, key, body)
+}
+
+func postTo(t *testing.T, app *fiber.App, target, contentType, key, body string) (int, string, string) {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	req.Header.Set(HeaderIdempotencyKey, key)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), resp.Header.Get(HeaderIdempotentReplayed)
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	useMiniredis(t)
+	var created atomic.Int32
+	app := fiber.New()
+	app.Post("/todo", Idempotency(time.Hour), func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": created.Add(1)})
+	})
+
+	status, body, replayed := postWithKey(t, app, "key-1", `{"title":"Buy milk"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.JSONEq(t, `{"id":1}`, body)
+	assert.Empty(t, replayed)
+
+	status, body, replayed = postWithKey(t, app, "key-1", `{"title":"Buy milk"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.JSONEq(t, `{"id":1}`, body)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, int32(1), created.Load())
+
+	// The same key cannot be reused for another request, while a new key creates another todo
+	status, _, _ = postWithKey(t, app, "key-1", `{"title":"Buy bread"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, body, _ = postWithKey(t, app, "key-2", `{"title":"Buy bread"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.JSONEq(t, `{"id":2}`, body)
+
+	// The query string and Content-Type are part of the request too
+	status, _, _ = postTo(t, app, "/todo?dry_run=true", fiber.MIMEApplicationJSON, "key-1", `{"title":"Buy milk"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	status, _, _ = postTo(t, app, "/todo", fiber.MIMETextPlain, "key-1", `{"title":"Buy milk"}`)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+	assert.Equal(t, int32(2), created.Load())
+}
+
+func TestIdempotencyBlocksConcurrentDuplicates(t *testing.T) {
+	useMiniredis(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	app := fiber.New()
+	app.Post("/todo", Idempotency(time.Hour), func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		status, _, _ := postWithKey(t, app, "key", "{}")
+		done <- status
+	}()
+	<-started
+
+	status, _, _ := postWithKey(t, app, "key", "{}")
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	close(release)
+	assert.Equal(t, fiber.StatusCreated, <-done)
+}
+
+func TestIdempotencyHoldsKeyWhileHandlerRuns(t *testing.T) {
+	server := useMiniredis(t)
+	previous := idempotencyLockTimeout
+	idempotencyLockTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { idempotencyLockTimeout = previous })
+
+	var handled atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	app := fiber.New()
+	app.Post("/todo", Idempotency(time.Hour), func(c *fiber.Ctx) error {
+		if handled.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		status, _, _ := postWithKey(t, app, "key", "{}")
+		done <- status
+	}()
+	<-started
+
+	// The handler outlives the lock timeout, which its server keeps extending
+	for i := 0; i < 3; i++ {
+		time.Sleep(150 * time.Millisecond)
+		server.FastForward(250 * time.Millisecond)
+	}
+	status, _, _ := postWithKey(t, app, "key", "{}")
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	close(release)
+	assert.Equal(t, fiber.StatusCreated, <-done)
+	assert.Equal(t, int32(1), handled.Load())
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	useMiniredis(t)
+	var attempts atomic.Int32
+	app := fiber.New()
+	app.Post("/todo", Idempotency(time.Hour), func(c *fiber.Ctx) error {
+		if attempts.Add(1) == 1 {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+		return c.Status(fiber.StatusCreated).SendString(strconv.Itoa(int(attempts.Load())))
+	})
+
+	status, _, _ := postWithKey(t, app, "key", "{}")
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+
+	status, body, replayed := postWithKey(t, app, "key", "{}")
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, "2", body)
+	assert.Empty(t, replayed)
+}
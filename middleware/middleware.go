@@ -1,15 +1,43 @@
 package middleware
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"os"
 	"strings"
 	"todolist/helper"
+	"todolist/models"
 )
 
 var jwtSecret = os.Getenv("API_KEY")
 
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrMissingUserID = errors.New("user ID is required")
+)
+
+// ParseToken validates a JWT issued at login and returns the ID and role of the user it was issued to
+func ParseToken(tokenString string) (uint, string, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) { return []byte(jwtSecret), nil })
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, "", ErrInvalidToken
+	}
+
+	userId, userIdExists := claims["userId"].(float64)
+	if !userIdExists {
+		return 0, "", ErrMissingUserID
+	}
+
+	role, _ := claims["role"].(string)
+	return uint(userId), role, nil
+}
+
 func Auth(c *fiber.Ctx) error {
 
 	tokenString := c.Get("Authorization")
@@ -18,26 +46,38 @@ func Auth(c *fiber.Ctx) error {
 		return nil
 	}
 
-	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) { return []byte(jwtSecret), nil })
-	if err != nil || !token.Valid {
+	userId, role, err := ParseToken(strings.TrimPrefix(tokenString, "Bearer "))
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		helper.RespondJSON(c, fiber.StatusUnauthorized, "Invalid token", nil, nil)
+		return nil
+	case errors.Is(err, ErrMissingUserID):
+		helper.RespondJSON(c, fiber.StatusUnauthorized, "User ID is required", nil, nil)
+		return nil
+	case err != nil:
 		helper.RespondJSON(c, fiber.StatusUnauthorized, "Unauthorized", nil, err.Error())
 		return nil
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		helper.RespondJSON(c, fiber.StatusUnauthorized, "Invalid token", nil, nil)
-		return nil
-	}
+	c.Locals("userId", userId)
+	c.Locals("role", role)
+	return c.Next()
+}
 
-	userId, userIdExists := claims["userId"].(float64)
-	if !userIdExists {
-		helper.RespondJSON(c, fiber.StatusUnauthorized, "User ID is required", nil, nil)
+// Admin only lets requests through when Auth has identified an admin user
+func Admin(c *fiber.Ctx) error {
+	if role, _ := c.Locals("role").(string); role != models.RoleAdmin {
+		helper.RespondJSON(c, fiber.StatusForbidden, "Admin access required", nil, nil)
 		return nil
 	}
+	return c.Next()
+}
 
-	c.Locals("userId", uint(userId))
+// TokenFromQuery lets clients that cannot set headers, such as the browser EventSource API,
+// pass their token in the access_token query parameter instead of the Authorization header
+func TokenFromQuery(c *fiber.Ctx) error {
+	if token := c.Query("access_token"); token != "" && c.Get("Authorization") == "" {
+		c.Request().Header.Set("Authorization", "Bearer "+token)
+	}
 	return c.Next()
 }
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+	"todolist/database"
+	"todolist/helper"
+)
+
+// RateLimitPolicy allows Limit requests per client in any sliding Window. Every route sharing a policy
+// draws from the same budget of the client.
+type RateLimitPolicy struct {
+	// Name distinguishes the budgets of different policies in Redis
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Policies of the login and registration endpoints, which the REST and gRPC APIs share so that a client
+// has the same budget on both
+var (
+	LoginPolicy    = RateLimitPolicy{Name: "login", Limit: 10, Window: time.Minute}
+	RegisterPolicy = RateLimitPolicy{Name: "register", Limit: 5, Window: time.Hour}
+)
+
+// slidingWindow keeps the times of the requests admitted in the last window in a sorted set, using the
+// clock of Redis so every server instance agrees on it. It returns whether the request is admitted, the
+// requests left in the window and the microseconds until the oldest admitted request leaves the window.
+var slidingWindow = redis.NewScript(`
+local key, limit, window, member = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), ARGV[3]
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, math.ceil(window / 1000))
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// RateLimit limits the requests of each client under policy, answering 429 Too Many Requests with a
+// Retry-After header once its budget is spent. Clients are identified by the user set by Auth, so it must
+// come after Auth on authenticated routes, and by IP address otherwise. Every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. When Redis is not configured or
+// unavailable, requests are let through rather than failing the API.
+func RateLimit(policy RateLimitPolicy) fiber.Handler {
+	limitHeader := strconv.Itoa(policy.Limit)
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		if database.RedisClient == nil {
+			return c.Next()
+		}
+
+		client := "ip:" + c.IP()
+		if userId, ok := c.Locals("userId").(uint); ok {
+			client = "user:" + strconv.FormatUint(uint64(userId), 10)
+		}
+
+		decision, err := policy.Allow(c.UserContext(), client)
+		if err != nil {
+			slog.WarnContext(c.UserContext(), "Failed to check rate limit", "policy", policy.Name, "error", err)
+			return c.Next()
+		}
+		reset := strconv.Itoa(int(math.Ceil(decision.Reset.Seconds())))
+
+		c.Set("RateLimit-Limit", limitHeader)
+		c.Set("RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
+		c.Set("RateLimit-Reset", reset)
+		c.Set("RateLimit-Policy", policyHeader)
+
+		if !decision.Allowed {
+			c.Set(fiber.HeaderRetryAfter, reset)
+			helper.RespondJSON(c, fiber.StatusTooManyRequests, "Too many requests", nil, nil)
+			return nil
+		}
+		return c.Next()
+	}
+}
+
+// RateLimitDecision is the outcome of a request checked against a policy
+type RateLimitDecision struct {
+	Allowed bool
+	// Remaining is the number of requests left in the window, and Reset the time until the oldest admitted
+	// request leaves it
+	Remaining int64
+	Reset     time.Duration
+}
+
+// Allow admits a request of client, such as "ip:192.0.2.1", when its budget under the policy is not spent.
+// It is the check RateLimit runs, for callers other than Fiber handlers. Redis must be configured.
+func (policy RateLimitPolicy) Allow(ctx context.Context, client string) (RateLimitDecision, error) {
+	result, err := slidingWindow.Run(ctx, database.RedisClient, []string{"ratelimit:" + policy.Name + ":" + client},
+		policy.Limit, policy.Window.Microseconds(), utils.UUIDv4()).Int64Slice()
+	if err != nil {
+		return RateLimitDecision{}, err
+	}
+	return RateLimitDecision{
+		Allowed:   result[0] == 1,
+		Remaining: result[1],
+		Reset:     time.Duration(result[2]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/database"
+)
+
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		database.RedisClient.Close()
+		database.RedisClient = previous
+	})
+	return server
+}
+
+// limitedApp serves /public limited per IP, and /private limited per user identified by the X-User header
+func limitedApp(policy RateLimitPolicy) *fiber.App {
+	limit := RateLimit(policy)
+	app := fiber.New()
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/public", limit, ok)
+	app.Get("/private", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Get("X-User"))
+		c.Locals("userId", uint(userId))
+		return c.Next()
+	}, limit, ok)
+	return app
+}
+
+func TestRateLimitPerIP(t *testing.T) {
+	useMiniredis(t)
+	app := limitedApp(RateLimitPolicy{Name: "test", Limit: 2, Window: time.Minute})
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		resp, err := app.Test(httptest.NewRequest("GET", "/public", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(remaining), resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "2;w=60", resp.Header.Get("RateLimit-Policy"))
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/public", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter))
+	require.NoError(t, err)
+	assert.InDelta(t, 60, retryAfter, 1)
+	assert.Equal(t, resp.Header.Get(fiber.HeaderRetryAfter), resp.Header.Get("RateLimit-Reset"))
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	useMiniredis(t)
+	app := limitedApp(RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute})
+
+	status := func(user string) int {
+		req := httptest.NewRequest("GET", "/private", nil)
+		req.Header.Set("X-User", user)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Users behind the same IP address have budgets of their own
+	assert.Equal(t, fiber.StatusOK, status("1"))
+	assert.Equal(t, fiber.StatusOK, status("2"))
+	assert.Equal(t, fiber.StatusTooManyRequests, status("1"))
+}
+
+func TestRateLimitLetsRequestsThroughWithoutRedis(t *testing.T) {
+	server := useMiniredis(t)
+	server.Close()
+	app := limitedApp(RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		resp, err := app.Test(httptest.NewRequest("GET", "/public", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AuditEvent is an append-only record of a single change made to a todo
+type AuditEvent struct {
+	ID        int
+	TodoID    int
+	UserID    sql.NullInt64
+	Operation string
+	Changes   map[string]FieldChange
+	CreatedAt time.Time
+}
+
+// FieldChange holds the value of a todo field before and after a change
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// ChecklistItem is a lightweight step of a todo that can only be ticked off
+type ChecklistItem struct {
+	ID       int
+	TodoID   int
+	Content  string
+	Done     bool
+	Position int
+}
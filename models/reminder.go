@@ -0,0 +1,20 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Reminder notifies Target through Channel OffsetMinutes before the due date of a todo
+type Reminder struct {
+	ID            int
+	TodoID        int
+	OffsetMinutes int
+	Channel       string
+	Target        string
+	RemindAt      time.Time
+	SentAt        sql.NullTime
+	FailedAt      sql.NullTime
+	Attempts      int
+	LastError     sql.NullString
+}
@@ -6,11 +6,24 @@ import (
 )
 
 type TodoList struct {
-	ID          int
-	Title       string
-	Description string
-	Status      string
-	DueDate     sql.NullTime
+	ID           int
+	Title        string
+	Description  string
+	Status       string
+	DueDate      sql.NullTime
+	DeletedAt    sql.NullTime
+	ParentID     sql.NullInt64
+	Position     int
+	AutoComplete bool
+	Recurrence   string
+	// Priority is an optional letter from A (highest) to Z, as in todo.txt
+	Priority   string
+	Occurrence int
+	// Version is incremented on every change so sync clients can detect conflicting edits
+	Version int
+	// OwnerID is the user who created the todo. Its events, webhook deliveries and calendar feed entries
+	// only reach that user.
+	OwnerID sql.NullInt64
 }
 
 //func (todo *TodoList) GetFormattedDueDate() map[string]interface{} {
@@ -4,4 +4,8 @@ type User struct {
 	ID       uint
 	Username string
 	Password string
+	Role     string
 }
+
+// RoleAdmin is the role of users allowed to access administrative endpoints
+const RoleAdmin = "admin"
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Webhook is a URL registered by a user to receive signed todo events
+type Webhook struct {
+	ID        int
+	UserID    uint
+	URL       string
+	Secret    string `json:",omitempty"`
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is a single queued or attempted delivery of an event to a webhook
+type WebhookDelivery struct {
+	ID             int
+	WebhookID      int
+	Event          string
+	Payload        string `json:"-"`
+	Status         string
+	Attempts       int
+	NextAttemptAt  sql.NullTime
+	LastStatusCode sql.NullInt64
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/docs"
+)
+
+var pathParamPattern = regexp.MustCompile(`:(\w+)`)
+
+type openAPIDocument struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadSpec(t *testing.T) openAPIDocument {
+	data, err := docs.Spec()
+	require.NoError(t, err)
+
+	var spec openAPIDocument
+	require.NoError(t, json.Unmarshal(data, &spec))
+	return spec
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	app := setupApp(t)
+	spec := loadSpec(t)
+
+	registered := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		// HEAD routes are added by fiber for every GET route
+		if route.Method == http.MethodHead || !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+
+		path := pathParamPattern.ReplaceAllString(strings.TrimPrefix(route.Path, "/api/v1"), "{$1}")
+		key := strings.ToLower(route.Method) + " " + path
+		registered[key] = true
+
+		_, ok := spec.Paths[path][strings.ToLower(route.Method)]
+		assert.True(t, ok, "%s %s is not documented in the OpenAPI document", route.Method, route.Path)
+	}
+
+	for path, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not routed", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	app := setupApp(t)
+
+	resp, err := app.Test(httpGet("/api/v1/openapi.json"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var spec map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	assert.Equal(t, "3.0.3", spec["openapi"])
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"ResponseData", "PaginatedTodos", "TodoList", "NullTime"} {
+		assert.Contains(t, schemas, name)
+	}
+
+	resp, err = app.Test(httpGet("/api/v1/docs"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	page, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(page), "openapi.json")
+
+	// The page loads Swagger UI from this API rather than a CDN
+	resp, err = app.Test(httpGet("/api/v1/docs/swagger-ui-bundle.js"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/javascript")
+	resp, err = app.Test(httpGet("/api/v1/docs/swagger-ui.css"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = app.Test(httpGet("/api/v1/docs/index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func httpGet(target string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, target, nil)
+	return req
+}
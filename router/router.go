@@ -2,56 +2,97 @@ package router
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"log"
-	"os"
 	"time"
 	"todolist/handler"
+	"todolist/health"
+	"todolist/helper"
+	"todolist/logging"
+	"todolist/metrics"
 	"todolist/middleware"
 	"todolist/services"
+	"todolist/tracing"
 )
 
-func Cors() fiber.Handler {
-	return func(ctx *fiber.Ctx) error {
-		ctx.Set("Access-Control-Allow-Origin", "*")
-		ctx.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-		ctx.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		return ctx.Next()
-	}
-}
-
-func Make() (*fiber.App, *os.File) {
-	logFile, err := os.OpenFile("server.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+// Make creates the application serving the REST API. It fails when the configuration read from the
+// environment is not valid.
+func Make() (*fiber.App, error) {
+	corsHandler, err := Cors(CorsConfigFromEnv())
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		return nil, err
 	}
 
-	app := fiber.New()
-
-	app.Use(logger.New(logger.Config{
-		// store logs
-		Output: logFile,
-		// the log format
-		Format: "[${time}] ${status} - ${method} ${path} - ${latency} ${locals:request_body} ${locals:response_body}\n",
-		// time format
-		TimeFormat: time.RFC3339,
-		// Skip logging for health checks or specific routes if needed
-		Next: func(c *fiber.Ctx) bool {
-			return c.Path() == "/health"
-		},
-	}))
-
-	app.Use(Cors())
-	v1 := app.Group("/api/v1")
+	app := fiber.New(ServerConfig())
+	// Probes are registered ahead of the middleware, so they are not logged, traced or counted
+	app.Get("/health/live", health.LiveHandler())
+	app.Get("/health/ready", health.ReadyHandler())
+
+	app.Use(logging.RequestIDMiddleware())
+	app.Use(tracing.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(logging.AccessLog())
+
+	app.Use(SecurityHeaders(helper.GetEnvDuration("HSTS_MAX_AGE", 365*24*time.Hour)))
+	app.Use(corsHandler)
+
+	// Rate limit policies: routes after Auth are limited per user, the others per client IP
+	var (
+		apiLimit      = middleware.RateLimit(middleware.RateLimitPolicy{Name: "api", Limit: 600, Window: time.Minute})
+		heavyLimit    = middleware.RateLimit(middleware.RateLimitPolicy{Name: "heavy", Limit: 30, Window: time.Minute})
+		publicLimit   = middleware.RateLimit(middleware.RateLimitPolicy{Name: "public", Limit: 120, Window: time.Minute})
+		loginLimit    = middleware.RateLimit(middleware.LoginPolicy)
+		registerLimit = middleware.RateLimit(middleware.RegisterPolicy)
+		// Every request counts against the budget of its IP before Auth runs, so that guessing tokens is
+		// limited too
+		clientLimit = middleware.RateLimit(middleware.RateLimitPolicy{Name: "client", Limit: 1200, Window: time.Minute})
+	)
+	// Responses to POST requests with an Idempotency-Key are replayed to retries for a day
+	idempotent := middleware.Idempotency(24 * time.Hour)
+
+	v1 := app.Group("/api/v1", clientLimit)
 	{
-		v1.Get("/todos", middleware.Auth, handler.GetAllTodosHandler)
-		v1.Get("/todo/:id", middleware.Auth, handler.GetTodoByIDHandler)
-		v1.Post("/todo", middleware.Auth, handler.CreateTodoHandler)
-		v1.Put("/todo/:id", middleware.Auth, handler.UpdateTodoHandler)
-		v1.Delete("/todo/:id", middleware.Auth, handler.DeleteTodoHandler)
-		v1.Post("/login", services.Login)
-		v1.Post("/register", handler.CreateUserHandler)
+		v1.Get("/todos", middleware.Auth, apiLimit, handler.GetAllTodosHandler)
+		v1.Post("/todos/bulk", middleware.Auth, heavyLimit, idempotent, handler.BulkTodosHandler)
+		v1.Get("/todos/changes", middleware.Auth, apiLimit, handler.GetChangesHandler)
+		v1.Post("/todos/changes", middleware.Auth, apiLimit, idempotent, handler.PushChangesHandler)
+		v1.Get("/todos/export", middleware.Auth, heavyLimit, handler.ExportTodosHandler)
+		v1.Post("/todos/import", middleware.Auth, heavyLimit, idempotent, handler.ImportTodosHandler)
+		v1.Post("/graphql", middleware.Auth, heavyLimit, handler.GraphQLHandler)
+		v1.Get("/todos/stream", middleware.TokenFromQuery, middleware.Auth, apiLimit, handler.StreamTodoEventsHandler)
+		v1.Get("/todo/:id", middleware.Auth, apiLimit, handler.GetTodoByIDHandler)
+		v1.Post("/todo", middleware.Auth, apiLimit, idempotent, handler.CreateTodoHandler)
+		v1.Put("/todo/:id", middleware.Auth, apiLimit, handler.UpdateTodoHandler)
+		v1.Delete("/todo/:id", middleware.Auth, apiLimit, handler.DeleteTodoHandler)
+		v1.Get("/todo/:id/history", middleware.Auth, apiLimit, handler.GetTodoHistoryHandler)
+		v1.Get("/todo/:id/occurrences", middleware.Auth, apiLimit, handler.GetUpcomingOccurrencesHandler)
+		v1.Get("/todo/:id/reminders", middleware.Auth, apiLimit, handler.GetRemindersHandler)
+		v1.Post("/todo/:id/reminders", middleware.Auth, apiLimit, idempotent, handler.CreateReminderHandler)
+		v1.Delete("/todo/:id/reminders/:reminderId", middleware.Auth, apiLimit, handler.DeleteReminderHandler)
+		v1.Get("/todo/:id/subtasks", middleware.Auth, apiLimit, handler.GetSubtasksHandler)
+		v1.Post("/todo/:id/subtasks", middleware.Auth, apiLimit, idempotent, handler.CreateSubtaskHandler)
+		v1.Put("/todo/:id/subtasks/order", middleware.Auth, apiLimit, handler.ReorderSubtasksHandler)
+		v1.Get("/todo/:id/checklist", middleware.Auth, apiLimit, handler.GetChecklistHandler)
+		v1.Post("/todo/:id/checklist", middleware.Auth, apiLimit, idempotent, handler.AddChecklistItemHandler)
+		v1.Put("/todo/:id/checklist/order", middleware.Auth, apiLimit, handler.ReorderChecklistHandler)
+		v1.Post("/todo/:id/checklist/:itemId/toggle", middleware.Auth, apiLimit, idempotent, handler.ToggleChecklistItemHandler)
+		v1.Delete("/todo/:id/checklist/:itemId", middleware.Auth, apiLimit, handler.DeleteChecklistItemHandler)
+		v1.Get("/trash", middleware.Auth, apiLimit, handler.GetTrashHandler)
+		v1.Post("/trash/:id/restore", middleware.Auth, apiLimit, idempotent, handler.RestoreTodoHandler)
+		v1.Delete("/trash/:id", middleware.Auth, apiLimit, handler.PurgeTodoHandler)
+		v1.Get("/audit", middleware.Auth, apiLimit, middleware.Admin, handler.GetAuditEventsHandler)
+		v1.Get("/webhooks", middleware.Auth, apiLimit, handler.ListWebhooksHandler)
+		v1.Post("/webhooks", middleware.Auth, apiLimit, idempotent, handler.CreateWebhookHandler)
+		v1.Delete("/webhooks/:id", middleware.Auth, apiLimit, handler.DeleteWebhookHandler)
+		v1.Get("/webhooks/:id/deliveries", middleware.Auth, apiLimit, handler.GetWebhookDeliveriesHandler)
+		v1.Post("/webhooks/:id/deliveries/:deliveryId/redeliver", middleware.Auth, apiLimit, idempotent, handler.RedeliverWebhookHandler)
+		v1.Post("/calendar/token", middleware.Auth, apiLimit, idempotent, handler.CreateCalendarTokenHandler)
+		v1.Delete("/calendar/token", middleware.Auth, apiLimit, handler.DeleteCalendarTokenHandler)
+		v1.Get("/calendar/:token/todos.ics", publicLimit, handler.CalendarFeedHandler)
+		v1.Post("/login", loginLimit, services.Login)
+		v1.Post("/register", registerLimit, handler.CreateUserHandler)
+		v1.Get("/openapi.json", publicLimit, handler.OpenAPIHandler)
+		v1.Get("/docs", publicLimit, handler.DocsHandler)
+		v1.Get("/docs/:file", publicLimit, handler.DocsAssetHandler)
 	}
 
-	return app, logFile
+	return app, nil
 }
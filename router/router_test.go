@@ -5,17 +5,19 @@ import (
 	"encoding/json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"testing"
 )
 
-func setupApp() *fiber.App {
-	app, _ := Make()
+func setupApp(t *testing.T) *fiber.App {
+	app, err := Make()
+	require.NoError(t, err)
 	return app
 }
 
 func TestCreateTodo(t *testing.T) {
-	app := setupApp()
+	app := setupApp(t)
 
 	todoData := map[string]string{
 		"title":       "Test Todo",
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"todolist/helper"
+)
+
+// CorsConfig is the policy for requests made by browsers from other origins
+type CorsConfig struct {
+	// AllowOrigins lists the origins allowed to call the API, such as https://app.example.com, without a path
+	// or trailing slash. https://*.example.com allows every subdomain. "*" alone allows every origin, which
+	// cannot be combined with AllowCredentials; no origin refuses cross-origin requests.
+	AllowOrigins []string
+	// AllowCredentials lets browsers send cookies and authorization headers along
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the answer to a preflight request
+	MaxAge time.Duration
+	// ExposeHeaders lists the response headers scripts of other origins may read
+	ExposeHeaders []string
+}
+
+// corsAllowHeaders are the request headers clients of the API send
+var corsAllowHeaders = []string{
+	fiber.HeaderAuthorization, fiber.HeaderContentType, fiber.HeaderXRequestID, "Idempotency-Key", "Last-Event-ID",
+	"traceparent", "tracestate",
+}
+
+// CorsConfigFromEnv reads the CORS policy from CORS_ALLOW_ORIGINS and CORS_EXPOSE_HEADERS, both
+// comma-separated, CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE
+func CorsConfigFromEnv() CorsConfig {
+	return CorsConfig{
+		AllowOrigins:     helper.GetEnvList("CORS_ALLOW_ORIGINS", nil),
+		AllowCredentials: helper.GetEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           helper.GetEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		ExposeHeaders: helper.GetEnvList("CORS_EXPOSE_HEADERS", []string{
+			fiber.HeaderXRequestID, fiber.HeaderRetryAfter, fiber.HeaderContentDisposition,
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Idempotent-Replayed",
+		}),
+	}
+}
+
+// Validate returns an error for the settings the cors middleware cannot apply
+func (cfg CorsConfig) Validate() error {
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			if len(cfg.AllowOrigins) > 1 {
+				return errors.New("CORS origin \"*\" cannot be combined with other origins")
+			}
+			if cfg.AllowCredentials {
+				return errors.New("CORS credentials cannot be allowed for every origin")
+			}
+			continue
+		}
+
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Contains(u.Host, "*") ||
+			u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || strings.ContainsAny(origin, "?#") {
+			return fmt.Errorf("invalid CORS origin %q, expected scheme://host[:port] without a path or trailing slash", origin)
+		}
+	}
+	return nil
+}
+
+// Cors applies cfg to cross-origin requests and answers their preflight requests. It fails when cfg is
+// not valid.
+func Cors(cfg CorsConfig) (fiber.Handler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := cors.Config{
+		AllowOrigins:     strings.Join(cfg.AllowOrigins, ","),
+		AllowMethods:     "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     strings.Join(corsAllowHeaders, ","),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+		ExposeHeaders:    strings.Join(cfg.ExposeHeaders, ","),
+	}
+	if len(cfg.AllowOrigins) == 0 {
+		// Without origins the cors middleware would allow all of them
+		config.AllowOriginsFunc = func(string) bool { return false }
+	}
+	return cors.New(config), nil
+}
+
+// defaultHTMLPolicy is the Content-Security-Policy of HTML pages that do not set their own
+const defaultHTMLPolicy = "default-src 'none'; img-src 'self'; style-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'self'"
+
+// SecurityHeaders sets the headers protecting browsers against content sniffing, clickjacking and downgrade
+// attacks. HTTPS responses carry Strict-Transport-Security for hstsMaxAge, which 0 disables, and HTML
+// pages a restrictive Content-Security-Policy unless their handler sets one.
+func SecurityHeaders(hstsMaxAge time.Duration) fiber.Handler {
+	hsts := "max-age=" + strconv.Itoa(int(hstsMaxAge.Seconds())) + "; includeSubDomains"
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		c.Set(fiber.HeaderXFrameOptions, "DENY")
+		c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+		if hstsMaxAge > 0 && c.Protocol() == "https" {
+			c.Set(fiber.HeaderStrictTransportSecurity, hsts)
+		}
+
+		err := c.Next()
+
+		contentType := string(c.Response().Header.ContentType())
+		if strings.HasPrefix(contentType, fiber.MIMETextHTML) && len(c.Response().Header.Peek(fiber.HeaderContentSecurityPolicy)) == 0 {
+			c.Set(fiber.HeaderContentSecurityPolicy, defaultHTMLPolicy)
+		}
+		return err
+	}
+}
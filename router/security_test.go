@@ -0,0 +1,143 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/database"
+	"todolist/docs"
+)
+
+const allowedOrigin = "https://app.example.com"
+
+func preflight(origin string) *http.Request {
+	req, _ := http.NewRequest(http.MethodOptions, "/api/v1/todo", nil)
+	req.Header.Set(fiber.HeaderOrigin, origin)
+	req.Header.Set(fiber.HeaderAccessControlRequestMethod, http.MethodPost)
+	req.Header.Set(fiber.HeaderAccessControlRequestHeaders, "Authorization, Idempotency-Key")
+	return req
+}
+
+func TestCorsPreflight(t *testing.T) {
+	t.Setenv("CORS_ALLOW_ORIGINS", allowedOrigin+", https://admin.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	app := setupApp(t)
+
+	resp, err := app.Test(preflight(allowedOrigin))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, allowedOrigin, resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", resp.Header.Get(fiber.HeaderAccessControlAllowCredentials))
+	assert.Contains(t, resp.Header.Get(fiber.HeaderAccessControlAllowMethods), "PATCH")
+	assert.Contains(t, resp.Header.Get(fiber.HeaderAccessControlAllowHeaders), "Idempotency-Key")
+	assert.Equal(t, "600", resp.Header.Get(fiber.HeaderAccessControlMaxAge))
+
+	resp, err = app.Test(preflight("https://evil.example.com"))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+
+	req := httpGet("/api/v1/openapi.json")
+	req.Header.Set(fiber.HeaderOrigin, allowedOrigin)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, allowedOrigin, resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+	assert.Contains(t, resp.Header.Get(fiber.HeaderAccessControlExposeHeaders), "RateLimit-Remaining")
+}
+
+func TestCorsRefusesEveryOriginByDefault(t *testing.T) {
+	resp, err := setupApp(t).Test(preflight(allowedOrigin))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Header.Get(fiber.HeaderAccessControlAllowOrigin))
+}
+
+func TestCorsConfigIsValidated(t *testing.T) {
+	valid := []CorsConfig{
+		{},
+		{AllowOrigins: []string{"*"}},
+		{AllowOrigins: []string{allowedOrigin, "http://localhost:3000", "https://*.example.com"}, AllowCredentials: true},
+	}
+	for _, cfg := range valid {
+		_, err := Cors(cfg)
+		assert.NoError(t, err, cfg.AllowOrigins)
+	}
+
+	invalid := []CorsConfig{
+		{AllowOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowOrigins: []string{"*", allowedOrigin}},
+		{AllowOrigins: []string{allowedOrigin + "/"}},
+		{AllowOrigins: []string{allowedOrigin + "/app"}},
+		{AllowOrigins: []string{"app.example.com"}},
+		{AllowOrigins: []string{"ftp://app.example.com"}},
+		{AllowOrigins: []string{"https://*"}},
+	}
+	for _, cfg := range invalid {
+		_, err := Cors(cfg)
+		assert.Error(t, err, cfg.AllowOrigins)
+	}
+
+	// Make reports the error instead of the cors middleware panicking
+	t.Setenv("CORS_ALLOW_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	_, err := Make()
+	assert.Error(t, err)
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	app := setupApp(t)
+
+	resp, err := app.Test(httpGet("/api/v1/openapi.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "nosniff", resp.Header.Get(fiber.HeaderXContentTypeOptions))
+	assert.Equal(t, "DENY", resp.Header.Get(fiber.HeaderXFrameOptions))
+	assert.Empty(t, resp.Header.Get(fiber.HeaderStrictTransportSecurity))
+	assert.Empty(t, resp.Header.Get(fiber.HeaderContentSecurityPolicy))
+
+	resp, err = app.Test(httpGet("/api/v1/docs"))
+	require.NoError(t, err)
+	assert.Equal(t, docs.PageCSP, resp.Header.Get(fiber.HeaderContentSecurityPolicy))
+	assert.Contains(t, docs.PageCSP, "'sha256-")
+	assert.NotContains(t, docs.PageCSP, "https:")
+
+	// HTML pages without a policy of their own get the default one, and HTTPS responses enable HSTS
+	page := fiber.New()
+	page.Use(SecurityHeaders(time.Hour))
+	page.Get("/", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString("<p>hi</p>")
+	})
+	req := httpGet("/")
+	req.Header.Set(fiber.HeaderXForwardedProto, "https")
+	resp, err = page.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, defaultHTMLPolicy, resp.Header.Get(fiber.HeaderContentSecurityPolicy))
+	assert.Equal(t, "max-age=3600; includeSubDomains", resp.Header.Get(fiber.HeaderStrictTransportSecurity))
+}
+
+func TestMetricsAreNotPublic(t *testing.T) {
+	resp, err := setupApp(t).Test(httpGet("/metrics"))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode, "metrics are served on their own listener")
+}
+
+func TestRequestsWithoutValidTokenAreRateLimited(t *testing.T) {
+	server := miniredis.RunT(t)
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		database.RedisClient.Close()
+		database.RedisClient = previous
+	})
+
+	req := httpGet("/api/v1/todos")
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer not-a-token")
+	resp, err := setupApp(t).Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "1200;w=60", resp.Header.Get("RateLimit-Policy"), "the client IP is limited before Auth runs")
+}
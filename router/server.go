@@ -0,0 +1,30 @@
+package router
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"todolist/helper"
+)
+
+// ServerConfig reads the limits of the HTTP server from HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT,
+// HTTP_IDLE_TIMEOUT and HTTP_BODY_LIMIT_MB. Forwarded headers are only honored from the addresses and
+// CIDR ranges in TRUSTED_PROXIES, which take the client IP from PROXY_HEADER (X-Forwarded-For by default);
+// the load balancer must overwrite that header rather than append to what the client sent.
+func ServerConfig() fiber.Config {
+	config := fiber.Config{
+		ReadTimeout: helper.GetEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		// The event stream and exports are written for longer than any sensible timeout, so there is none by default
+		WriteTimeout: helper.GetEnvDuration("HTTP_WRITE_TIMEOUT", 0),
+		IdleTimeout:  helper.GetEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		BodyLimit:    helper.GetEnvInt("HTTP_BODY_LIMIT_MB", 4) << 20,
+
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          helper.GetEnvList("TRUSTED_PROXIES", nil),
+		EnableIPValidation:      true,
+	}
+	if len(config.TrustedProxies) > 0 {
+		config.ProxyHeader = helper.GetEnv("PROXY_HEADER", fiber.HeaderXForwardedFor)
+	}
+	return config
+}
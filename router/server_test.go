@@ -0,0 +1,49 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clientIP answers with the IP address the server attributes a request sent through a proxy to
+func clientIP(t *testing.T) string {
+	app := fiber.New(ServerConfig())
+	app.Get("/ip", func(c *fiber.Ctx) error { return c.SendString(c.IP()) })
+
+	req := httpGet("/ip")
+	req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	// Requests sent through app.Test come from 0.0.0.0
+	assert.Equal(t, "0.0.0.0", clientIP(t))
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 0.0.0.0")
+	assert.Equal(t, "203.0.113.7", clientIP(t))
+}
+
+func TestBodyLimit(t *testing.T) {
+	t.Setenv("HTTP_BODY_LIMIT_MB", "1")
+	app := fiber.New(ServerConfig())
+	app.Post("/echo", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	// The server refuses to read a body over the limit
+	req, _ := http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(make([]byte, 2<<20)))
+	_, err := app.Test(req)
+	assert.ErrorContains(t, err, "body size exceeds the given limit")
+
+	req, _ = http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(make([]byte, 512<<10)))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+}
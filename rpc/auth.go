@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"todolist/middleware"
+	"todolist/models"
+	"todolist/services"
+	"todolist/todopb"
+)
+
+// publicMethods can be called without a token, since they are how a token is obtained
+var publicMethods = map[string]bool{
+	todopb.AuthService_Login_FullMethodName:    true,
+	todopb.AuthService_Register_FullMethodName: true,
+}
+
+// authenticate checks the bearer token of a call and returns a context carrying the user as the actor of any change
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+	}
+
+	userId, _, err := middleware.ParseToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return services.WithActor(ctx, userId), nil
+}
+
+func unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream replaces the context of a stream with the one carrying the authenticated user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+type authServer struct {
+	todopb.UnimplementedAuthServiceServer
+}
+
+func (s *authServer) Login(ctx context.Context, req *todopb.LoginRequest) (*todopb.LoginResponse, error) {
+	token, err := services.Authenticate(ctx, req.GetUsername(), req.GetPassword())
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidPassword):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &todopb.LoginResponse{Token: token}, nil
+}
+
+func (s *authServer) Register(ctx context.Context, req *todopb.RegisterRequest) (*todopb.User, error) {
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and password are required")
+	}
+
+	user := &models.User{Username: req.GetUsername(), Password: req.GetPassword()}
+	if _, err := services.CreateUser(ctx, user); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &todopb.User{Id: uint64(user.ID), Username: user.Username}, nil
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"todolist/database"
+	"todolist/middleware"
+	"todolist/todopb"
+)
+
+// limitedMethods are rate limited per client IP under the same policies as their REST counterparts, as
+// they can be called without a token
+var limitedMethods = map[string]middleware.RateLimitPolicy{
+	todopb.AuthService_Login_FullMethodName:    middleware.LoginPolicy,
+	todopb.AuthService_Register_FullMethodName: middleware.RegisterPolicy,
+}
+
+// unaryRateLimit refuses calls of limitedMethods with ResourceExhausted once the budget of the client is
+// spent, telling it when to retry in the "retry-after" header. As with middleware.RateLimit, calls are let
+// through when Redis is not configured or unavailable.
+func unaryRateLimit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	policy, ok := limitedMethods[info.FullMethod]
+	if !ok || database.RedisClient == nil {
+		return handler(ctx, req)
+	}
+
+	decision, err := policy.Allow(ctx, "ip:"+peerIP(ctx))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check rate limit", "policy", policy.Name, "error", err)
+		return handler(ctx, req)
+	}
+	if !decision.Allowed {
+		reset := strconv.Itoa(int(math.Ceil(decision.Reset.Seconds())))
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", reset))
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+	return handler(ctx, req)
+}
+
+// peerIP returns the IP address the call came from
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"todolist/database"
+	"todolist/middleware"
+	"todolist/todopb"
+)
+
+func TestLoginIsRateLimitedPerIP(t *testing.T) {
+	server := miniredis.RunT(t)
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		database.RedisClient.Close()
+		database.RedisClient = previous
+	})
+
+	call := func(ip string, method string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+		_, err := unaryRateLimit(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		return err
+	}
+
+	login := todopb.AuthService_Login_FullMethodName
+	for i := 0; i < middleware.LoginPolicy.Limit; i++ {
+		assert.NoError(t, call("192.0.2.1", login))
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("192.0.2.1", login)))
+	assert.NoError(t, call("192.0.2.2", login), "other clients keep their budget")
+	assert.NoError(t, call("192.0.2.1", todopb.TodoService_ListTodos_FullMethodName), "calls with a token are not limited here")
+
+	// The REST login draws from the same budget
+	decision, err := middleware.LoginPolicy.Allow(context.Background(), "ip:192.0.2.2")
+	assert.NoError(t, err)
+	assert.EqualValues(t, middleware.LoginPolicy.Limit-2, decision.Remaining)
+}
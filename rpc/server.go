@@ -0,0 +1,65 @@
+// Package rpc serves the todo and auth operations over gRPC, on top of the same services as the REST API
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"todolist/services"
+	"todolist/todopb"
+)
+
+// NewServer creates a gRPC server exposing TodoService and AuthService. Every call except those of
+// AuthService must carry a bearer token in its "authorization" metadata, as with middleware.Auth. The calls
+// of AuthService are rate limited per client IP instead.
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(unaryRateLimit, unaryAuth), grpc.ChainStreamInterceptor(streamAuth))
+	server := grpc.NewServer(opts...)
+	todopb.RegisterTodoServiceServer(server, &todoServer{})
+	todopb.RegisterAuthServiceServer(server, &authServer{})
+	return server
+}
+
+// ListenAndServe serves gRPC requests on addr until ctx is cancelled, then lets the calls in flight finish
+// for up to grace before closing their connections. It returns once the server has stopped.
+func ListenAndServe(ctx context.Context, addr string, grace time.Duration, opts ...grpc.ServerOption) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := NewServer(opts...)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		// Streams like WatchTodos never finish on their own, so the graceful stop is cut short after grace
+		timer := time.AfterFunc(grace, server.Stop)
+		defer timer.Stop()
+		server.GracefulStop()
+	}()
+	if err := server.Serve(listener); err != nil {
+		return err
+	}
+	<-stopped
+	return nil
+}
+
+// statusError maps an error returned by the services package to a gRPC status
+func statusError(err error) error {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, services.ErrTodoNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &validationErrors), errors.Is(err, services.ErrInvalidParent):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
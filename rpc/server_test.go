@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"todolist/models"
+	"todolist/services"
+	"todolist/todopb"
+)
+
+func dialTestServer(t *testing.T) todopb.TodoServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return todopb.NewTodoServiceClient(conn)
+}
+
+func TestCallsWithoutValidTokenAreRejected(t *testing.T) {
+	client := dialTestServer(t)
+
+	_, err := client.ListTodos(context.Background(), &todopb.ListTodosRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	_, err = client.GetTodo(ctx, &todopb.GetTodoRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := client.WatchTodos(context.Background(), &todopb.WatchTodosRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthenticate(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId": 7,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(""))
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	ctx, err = authenticate(ctx, todopb.TodoService_ListTodos_FullMethodName)
+	require.NoError(t, err)
+	actor, ok := services.ActorFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), actor)
+
+	// Logging in and registering are how clients get a token in the first place
+	_, err = authenticate(context.Background(), todopb.AuthService_Login_FullMethodName)
+	assert.NoError(t, err)
+	_, err = authenticate(context.Background(), todopb.TodoService_DeleteTodo_FullMethodName)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTodoConversion(t *testing.T) {
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	todo := &models.TodoList{
+		ID:          4,
+		Title:       "Water plants",
+		Description: "Balcony",
+		Status:      "pending",
+		DueDate:     sql.NullTime{Time: due, Valid: true},
+		ParentID:    sql.NullInt64{Int64: 2, Valid: true},
+		Recurrence:  "FREQ=WEEKLY",
+		Priority:    "B",
+		Occurrence:  3,
+		Version:     5,
+	}
+
+	msg := todoToProto(todo)
+	assert.Equal(t, int64(4), msg.GetId())
+	assert.Equal(t, due, msg.GetDueDate().AsTime())
+	assert.Equal(t, int64(2), msg.GetParentId())
+	assert.Equal(t, int32(5), msg.GetVersion())
+
+	back := todoFromProto(msg)
+	assert.Equal(t, todo.Title, back.Title)
+	assert.Equal(t, todo.DueDate, back.DueDate)
+	assert.Equal(t, todo.ParentID, back.ParentID)
+	assert.Equal(t, todo.Priority, back.Priority)
+	assert.Zero(t, back.ID, "the ID is chosen by the server")
+
+	msg.DueDate, msg.ParentId = nil, 0
+	back = todoFromProto(msg)
+	assert.False(t, back.DueDate.Valid)
+	assert.False(t, back.ParentID.Valid)
+}
+
+func TestListenAndServeReturnsOnceStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ListenAndServe(ctx, "127.0.0.1:0", time.Second) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned before being stopped: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not stop")
+	}
+}
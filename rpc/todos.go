@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"todolist/models"
+	"todolist/services"
+	"todolist/todopb"
+)
+
+type todoServer struct {
+	todopb.UnimplementedTodoServiceServer
+}
+
+func (s *todoServer) ListTodos(ctx context.Context, req *todopb.ListTodosRequest) (*todopb.ListTodosResponse, error) {
+	// Fall back to the first page of 10, as the REST API does
+	page, limit := int(req.GetPage()), int(req.GetPageSize())
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	paginatedTodos, err := services.GetAllTodos(ctx, page, limit)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &todopb.ListTodosResponse{
+		CurrentPage: int32(paginatedTodos.CurrentPage),
+		TotalPages:  int32(paginatedTodos.TotalPages),
+		TotalTodos:  int32(paginatedTodos.TotalTasks),
+	}
+	for i := range paginatedTodos.Todos {
+		resp.Todos = append(resp.Todos, todoToProto(&paginatedTodos.Todos[i]))
+	}
+	return resp, nil
+}
+
+func (s *todoServer) GetTodo(ctx context.Context, req *todopb.GetTodoRequest) (*todopb.Todo, error) {
+	todo, err := services.GetTodoByID(ctx, strconv.FormatInt(req.GetId(), 10))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if todo == nil {
+		return nil, status.Error(codes.NotFound, services.ErrTodoNotFound.Error())
+	}
+	return todoToProto(todo), nil
+}
+
+func (s *todoServer) CreateTodo(ctx context.Context, req *todopb.CreateTodoRequest) (*todopb.Todo, error) {
+	if req.GetTodo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "todo is required")
+	}
+
+	todo, err := services.CreateTodo(ctx, todoFromProto(req.GetTodo()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return todoToProto(todo), nil
+}
+
+func (s *todoServer) UpdateTodo(ctx context.Context, req *todopb.UpdateTodoRequest) (*todopb.Todo, error) {
+	if req.GetTodo() == nil {
+		return nil, status.Error(codes.InvalidArgument, "todo is required")
+	}
+
+	todo := todoFromProto(req.GetTodo())
+	todo.ID = int(req.GetId())
+	todo, err := services.UpdateTodoByID(ctx, strconv.FormatInt(req.GetId(), 10), todo)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return todoToProto(todo), nil
+}
+
+func (s *todoServer) DeleteTodo(ctx context.Context, req *todopb.DeleteTodoRequest) (*emptypb.Empty, error) {
+	if err := services.DeleteTodoByID(ctx, strconv.FormatInt(req.GetId(), 10)); err != nil {
+		return nil, statusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WatchTodos streams the events about the todos of the authenticated user like the Server-Sent Events
+// route, replaying the events missed since last_event_id before the live ones
+func (s *todoServer) WatchTodos(req *todopb.WatchTodosRequest, stream todopb.TodoService_WatchTodosServer) error {
+	lastEventID := req.GetLastEventId()
+	userID, ok := services.ActorFromContext(stream.Context())
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing user")
+	}
+
+	// Subscribe before replaying so no event is lost between the two
+	events, unsubscribe := services.SubscribeTodoEvents(userID)
+	defer unsubscribe()
+
+	if lastEventID != "" {
+		missed, err := services.ReplayTodoEvents(stream.Context(), userID, lastEventID)
+		if err != nil {
+			return statusError(err)
+		}
+		for _, event := range missed {
+			if err := stream.Send(eventToProto(event)); err != nil {
+				return err
+			}
+			lastEventID = event.ID
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "too slow to keep up with todo events; resume from the last event received")
+			}
+			if lastEventID != "" && services.CompareEventIDs(event.ID, lastEventID) <= 0 {
+				continue
+			}
+			if err := stream.Send(eventToProto(event)); err != nil {
+				return err
+			}
+			lastEventID = event.ID
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func todoToProto(todo *models.TodoList) *todopb.Todo {
+	msg := &todopb.Todo{
+		Id:           int64(todo.ID),
+		Title:        todo.Title,
+		Description:  todo.Description,
+		Status:       todo.Status,
+		ParentId:     todo.ParentID.Int64,
+		Position:     int32(todo.Position),
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   todo.Recurrence,
+		Priority:     todo.Priority,
+		Occurrence:   int32(todo.Occurrence),
+		Version:      int32(todo.Version),
+	}
+	if todo.DueDate.Valid {
+		msg.DueDate = timestamppb.New(todo.DueDate.Time)
+	}
+	return msg
+}
+
+// todoFromProto reads the fields of a todo a client may set; the ID, position and version are managed by the server
+func todoFromProto(msg *todopb.Todo) *models.TodoList {
+	todo := &models.TodoList{
+		Title:        msg.GetTitle(),
+		Description:  msg.GetDescription(),
+		Status:       msg.GetStatus(),
+		ParentID:     sql.NullInt64{Int64: msg.GetParentId(), Valid: msg.GetParentId() != 0},
+		AutoComplete: msg.GetAutoComplete(),
+		Recurrence:   msg.GetRecurrence(),
+		Priority:     msg.GetPriority(),
+	}
+	if msg.GetDueDate() != nil {
+		todo.DueDate = sql.NullTime{Time: msg.GetDueDate().AsTime(), Valid: true}
+	}
+	return todo
+}
+
+func eventToProto(event services.TodoEvent) *todopb.TodoEvent {
+	msg := &todopb.TodoEvent{
+		Id:         event.ID,
+		Type:       event.Type,
+		OccurredAt: timestamppb.New(event.OccurredAt),
+	}
+	if event.ActorID != nil {
+		msg.ActorId = uint64(*event.ActorID)
+	}
+	if event.Todo != nil {
+		msg.Todo = todoToProto(event.Todo)
+	}
+	return msg
+}
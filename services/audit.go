@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// Audit operations recorded for todo changes
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the ID of the user performing the request,
+// which is recorded as the actor of any audited change made with it
+func WithActor(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFromContext returns the user ID stored by WithActor, if any
+func ActorFromContext(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(actorKey{}).(uint)
+	return userID, ok
+}
+
+type PaginatedAuditEvents struct {
+	Events      []models.AuditEvent `json:"events"`
+	CurrentPage int                 `json:"current_page"`
+	TotalPages  int                 `json:"total_pages"`
+	TotalEvents int                 `json:"total_events"`
+}
+
+// AuditFilter narrows down an audit query; zero values are ignored
+type AuditFilter struct {
+	TodoID    string
+	UserID    string
+	Operation string
+	From      time.Time
+	To        time.Time
+}
+
+// todoFields returns the audited fields of a todo, keyed by their JSON name
+func todoFields(todo *models.TodoList) map[string]interface{} {
+	if todo == nil {
+		return map[string]interface{}{}
+	}
+
+	fields := map[string]interface{}{
+		"title":         todo.Title,
+		"description":   todo.Description,
+		"status":        todo.Status,
+		"due_date":      nil,
+		"deleted_at":    nil,
+		"auto_complete": todo.AutoComplete,
+		"recurrence":    todo.Recurrence,
+		"priority":      todo.Priority,
+	}
+	if todo.DueDate.Valid {
+		fields["due_date"] = todo.DueDate.Time.Format("2006-01-02")
+	}
+	if todo.DeletedAt.Valid {
+		fields["deleted_at"] = todo.DeletedAt.Time.Format(time.RFC3339)
+	}
+	return fields
+}
+
+// diffTodos returns the fields that differ between before and after; either may be nil
+func diffTodos(before, after *models.TodoList) map[string]models.FieldChange {
+	beforeFields, afterFields := todoFields(before), todoFields(after)
+
+	changes := make(map[string]models.FieldChange)
+	for _, fields := range []map[string]interface{}{beforeFields, afterFields} {
+		for name := range fields {
+			if _, seen := changes[name]; seen {
+				continue
+			}
+			if beforeFields[name] != afterFields[name] {
+				changes[name] = models.FieldChange{Before: beforeFields[name], After: afterFields[name]}
+			}
+		}
+	}
+	return changes
+}
+
+// recordAudit appends an audit event for a todo change within tx, using the actor stored in ctx,
+// and queues the webhook deliveries and streaming events the change triggers
+func recordAudit(ctx context.Context, tx *sql.Tx, todoID, operation string, before, after *models.TodoList) error {
+	diff := diffTodos(before, after)
+	changes, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	var actor sql.NullInt64
+	if userID, ok := ActorFromContext(ctx); ok {
+		actor = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+
+	query := `INSERT INTO todo_audit (todo_id, user_id, operation, changes) VALUES (:1, :2, :3, :4)`
+	if _, err = tx.ExecContext(ctx, query, todoID, actor, operation, string(changes)); err != nil {
+		return err
+	}
+
+	queueTodoEvents(ctx, operation, before, after)
+	return enqueueWebhookEvents(ctx, tx, operation, before, after, diff)
+}
+
+// GetTodoHistory retrieves a page of audit events for a single todo, oldest first
+func GetTodoHistory(ctx context.Context, id string, page, limit int) (*PaginatedAuditEvents, error) {
+	return QueryAuditEvents(ctx, AuditFilter{TodoID: id}, page, limit)
+}
+
+// QueryAuditEvents retrieves a page of audit events matching filter, oldest first
+func QueryAuditEvents(ctx context.Context, filter AuditFilter, page, limit int) (*PaginatedAuditEvents, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.TodoID != "" {
+		addCondition("todo_id = :%d", filter.TodoID)
+	}
+	if filter.UserID != "" {
+		addCondition("user_id = :%d", filter.UserID)
+	}
+	if filter.Operation != "" {
+		addCondition("operation = :%d", filter.Operation)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= :%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < :%d", filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalEvents int
+	countQuery := `SELECT COUNT(*) FROM todo_audit ` + where
+	if err := database.DB.QueryRowContext(ctx, countQuery, args...).Scan(&totalEvents); err != nil {
+		return nil, err
+	}
+
+	startRow := (page - 1) * limit
+	query := fmt.Sprintf(`
+        SELECT id, todo_id, user_id, operation, changes, created_at
+        FROM (
+            SELECT id, todo_id, user_id, operation, changes, created_at, ROW_NUMBER() OVER (ORDER BY created_at, id) AS rn
+            FROM todo_audit %s
+        ) WHERE rn BETWEEN :%d AND :%d
+    `, where, len(args)+1, len(args)+2)
+	rows, err := database.DB.QueryContext(ctx, query, append(args, startRow+1, startRow+limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var changes sql.NullString
+		if err := rows.Scan(&event.ID, &event.TodoID, &event.UserID, &event.Operation, &changes, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if changes.Valid {
+			if err := json.Unmarshal([]byte(changes.String), &event.Changes); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedAuditEvents{
+		Events:      events,
+		CurrentPage: page,
+		TotalPages:  (totalEvents + limit - 1) / limit,
+		TotalEvents: totalEvents,
+	}, nil
+}
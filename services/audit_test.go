@@ -0,0 +1,27 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todolist/models"
+)
+
+func TestDiffTodos(t *testing.T) {
+	due := sql.NullTime{Time: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), Valid: true}
+	before := &models.TodoList{ID: 1, Title: "Write report", Description: "Q4", Status: "pending", DueDate: due}
+	after := &models.TodoList{ID: 1, Title: "Write report", Description: "Q4", Status: "completed", DueDate: due}
+
+	assert.Equal(t, map[string]models.FieldChange{
+		"status": {Before: "pending", After: "completed"},
+	}, diffTodos(before, after))
+
+	created := diffTodos(nil, after)
+	assert.Equal(t, models.FieldChange{Before: nil, After: "Write report"}, created["title"])
+	assert.Equal(t, models.FieldChange{Before: nil, After: "2024-12-31"}, created["due_date"])
+	assert.NotContains(t, created, "deleted_at")
+
+	assert.Empty(t, diffTodos(before, before))
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// maxInListSize is the maximum number of expressions Oracle accepts in an IN list
+const maxInListSize = 1000
+
+// QueryTodos retrieves a page of the todos matching filter, ordered by ID
+func QueryTodos(ctx context.Context, filter TodoFilter, page, limit int) (*PaginatedTodos, error) {
+	where, args := filter.where()
+
+	var total int
+	if err := database.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM todolist WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM todolist WHERE %s ORDER BY id OFFSET :%d ROWS FETCH NEXT :%d ROWS ONLY`,
+		todoColumns, where, len(args)+1, len(args)+2)
+	rows, err := database.DB.QueryContext(ctx, query, append(args, (page-1)*limit, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &PaginatedTodos{
+		Todos:       []models.TodoList{},
+		CurrentPage: page,
+		TotalPages:  (total + limit - 1) / limit,
+		TotalTasks:  total,
+	}
+	for rows.Next() {
+		var todo models.TodoList
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+		result.Todos = append(result.Todos, todo)
+	}
+	return result, rows.Err()
+}
+
+// GetTodosByIDs retrieves the todos outside the trash with the given IDs in as few queries as possible
+func GetTodosByIDs(ctx context.Context, ids []int) (map[int]*models.TodoList, error) {
+	todos := make(map[int]*models.TodoList, len(ids))
+	err := queryInChunks(ctx, `SELECT `+todoColumns+` FROM todolist WHERE deleted_at IS NULL AND id IN (%s)`, ids,
+		func(row rowScanner) error {
+			var todo models.TodoList
+			if err := scanTodo(row, &todo); err != nil {
+				return err
+			}
+			todos[todo.ID] = &todo
+			return nil
+		})
+	return todos, err
+}
+
+// GetSubtasksByParents retrieves the subtasks of several todos at once, keyed by parent ID and in display order
+func GetSubtasksByParents(ctx context.Context, parentIDs []int) (map[int][]models.TodoList, error) {
+	subtasks := make(map[int][]models.TodoList, len(parentIDs))
+	err := queryInChunks(ctx, `SELECT `+todoColumns+` FROM todolist WHERE deleted_at IS NULL AND parent_id IN (%s) ORDER BY position, id`, parentIDs,
+		func(row rowScanner) error {
+			var todo models.TodoList
+			if err := scanTodo(row, &todo); err != nil {
+				return err
+			}
+			parentID := int(todo.ParentID.Int64)
+			subtasks[parentID] = append(subtasks[parentID], todo)
+			return nil
+		})
+	return subtasks, err
+}
+
+// GetChecklistsByTodos retrieves the checklists of several todos at once, keyed by todo ID and in display order
+func GetChecklistsByTodos(ctx context.Context, todoIDs []int) (map[int][]models.ChecklistItem, error) {
+	checklists := make(map[int][]models.ChecklistItem, len(todoIDs))
+	err := queryInChunks(ctx, `SELECT id, todo_id, content, done, position FROM todo_checklist WHERE todo_id IN (%s) ORDER BY position, id`, todoIDs,
+		func(row rowScanner) error {
+			var item models.ChecklistItem
+			var done int
+			if err := row.Scan(&item.ID, &item.TodoID, &item.Content, &done, &item.Position); err != nil {
+				return err
+			}
+			item.Done = done == 1
+			checklists[item.TodoID] = append(checklists[item.TodoID], item)
+			return nil
+		})
+	return checklists, err
+}
+
+// GetUsersByIDs retrieves several users at once, keyed by ID
+func GetUsersByIDs(ctx context.Context, ids []int) (map[int]*models.User, error) {
+	users := make(map[int]*models.User, len(ids))
+	err := queryInChunks(ctx, `SELECT id, username, role FROM users WHERE id IN (%s)`, ids,
+		func(row rowScanner) error {
+			var user models.User
+			if err := row.Scan(&user.ID, &user.Username, &user.Role); err != nil {
+				return err
+			}
+			users[int(user.ID)] = &user
+			return nil
+		})
+	return users, err
+}
+
+// queryInChunks runs query once per chunk of ids that fits in an IN list, substituting the binds for %s,
+// and passes every row to scan
+func queryInChunks(ctx context.Context, query string, ids []int, scan func(row rowScanner) error) error {
+	for start := 0; start < len(ids); start += maxInListSize {
+		chunk := ids[start:min(start+maxInListSize, len(ids))]
+
+		binds := make([]string, len(chunk))
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			binds[i] = fmt.Sprintf(":%d", i+1)
+			args[i] = id
+		}
+
+		if err := func() error {
+			rows, err := database.DB.QueryContext(ctx, fmt.Sprintf(query, strings.Join(binds, ", ")), args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				if err := scan(rows); err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
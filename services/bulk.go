@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"todolist/database"
+	"todolist/models"
+)
+
+// Bulk operation types accepted by ExecuteBulk
+const (
+	BulkCreate   = "create"
+	BulkUpdate   = "update"
+	BulkDelete   = "delete"
+	BulkComplete = "complete"
+)
+
+// Bulk execution modes: atomic rolls everything back on the first failure,
+// partial keeps the operations that succeeded
+const (
+	BulkModeAtomic  = "atomic"
+	BulkModePartial = "partial"
+)
+
+// MaxBulkOperations caps the number of operations accepted in a single bulk request
+const MaxBulkOperations = 500
+
+var (
+	ErrBulkRolledBack = errors.New("bulk operation rolled back")
+	ErrInvalidBulk    = errors.New("invalid bulk request")
+)
+
+type BulkOperation struct {
+	Op   string           `json:"op"`
+	ID   string           `json:"id"`
+	Todo *models.TodoList `json:"todo"`
+}
+
+type BulkRequest struct {
+	Mode       string          `json:"mode"`
+	Operations []BulkOperation `json:"operations"`
+}
+
+type BulkResult struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	ID     string           `json:"id,omitempty"`
+	Status int              `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Todo   *models.TodoList `json:"todo,omitempty"`
+}
+
+// ExecuteBulk runs every operation of req inside a single database transaction and reports the outcome of each one.
+// In atomic mode the first failure rolls back the whole transaction and ErrBulkRolledBack is returned with the results;
+// in partial mode each operation runs under its own savepoint so failures only undo that operation.
+func ExecuteBulk(ctx context.Context, req BulkRequest) ([]BulkResult, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	if req.Mode == "" {
+		req.Mode = BulkModeAtomic
+	}
+	if req.Mode != BulkModeAtomic && req.Mode != BulkModePartial {
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidBulk, req.Mode)
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > MaxBulkOperations {
+		return nil, fmt.Errorf("%w: between 1 and %d operations are required", ErrInvalidBulk, MaxBulkOperations)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkResult, len(req.Operations))
+	var touched []string
+	failed := false
+
+	for i, op := range req.Operations {
+		results[i] = BulkResult{Index: i, Op: op.Op, ID: op.ID}
+		if failed {
+			results[i].Status = fiber.StatusFailedDependency
+			results[i].Error = "not executed because an earlier operation failed"
+			continue
+		}
+
+		mark := events.mark()
+		if req.Mode == BulkModePartial {
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		}
+
+		todo, err := executeBulkOperation(ctx, tx, op)
+		if err != nil {
+			results[i].Status = bulkErrorStatus(err)
+			results[i].Error = err.Error()
+
+			if req.Mode == BulkModeAtomic {
+				failed = true
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+			events.truncate(mark)
+			continue
+		}
+
+		if todo != nil {
+			results[i].ID = strconv.Itoa(todo.ID)
+		}
+		results[i].Status = fiber.StatusOK
+		if op.Op == BulkCreate {
+			results[i].Status = fiber.StatusCreated
+		}
+		results[i].Todo = todo
+		touched = append(touched, results[i].ID)
+	}
+
+	if failed {
+		// Nothing was committed, so report the successful operations as rolled back too
+		for i := range results {
+			if results[i].Status < 300 {
+				results[i].Status = fiber.StatusFailedDependency
+				results[i].Error = "rolled back because another operation failed"
+				results[i].Todo = nil
+			}
+		}
+		return results, ErrBulkRolledBack
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, touched...)
+	events.publish(ctx)
+	return results, nil
+}
+
+func executeBulkOperation(ctx context.Context, tx *sql.Tx, op BulkOperation) (*models.TodoList, error) {
+	switch op.Op {
+	case BulkCreate:
+		if op.Todo == nil {
+			return nil, fmt.Errorf("%w: todo is required", ErrInvalidBulk)
+		}
+		return op.Todo, createTodoTx(ctx, tx, op.Todo)
+	case BulkUpdate:
+		if op.Todo == nil || op.ID == "" {
+			return nil, fmt.Errorf("%w: id and todo are required", ErrInvalidBulk)
+		}
+		return op.Todo, updateTodoTx(ctx, tx, op.ID, op.Todo)
+	case BulkDelete:
+		if op.ID == "" {
+			return nil, fmt.Errorf("%w: id is required", ErrInvalidBulk)
+		}
+		return nil, deleteTodoTx(ctx, tx, op.ID)
+	case BulkComplete:
+		if op.ID == "" {
+			return nil, fmt.Errorf("%w: id is required", ErrInvalidBulk)
+		}
+		return completeTodoTx(ctx, tx, op.ID)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidBulk, op.Op)
+	}
+}
+
+// bulkErrorStatus maps the error of a single bulk operation to an HTTP status code
+func bulkErrorStatus(err error) int {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrTodoNotFound):
+		return fiber.StatusNotFound
+	case errors.As(err, &validationErrors), errors.Is(err, ErrInvalidParent):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, ErrInvalidBulk):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
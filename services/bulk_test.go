@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/database"
+	"todolist/models"
+)
+
+// useMockDB replaces the database with a mock expecting the statements the test sets up, in order, and
+// Redis with an in-memory server. The cleanup fails the test if an expected statement did not run.
+func useMockDB(t *testing.T) (sqlmock.Sqlmock, *miniredis.Miniredis) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	server := miniredis.RunT(t)
+
+	previousDB, previousRedis := database.DB, database.RedisClient
+	database.DB = db
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		database.RedisClient.Close()
+		db.Close()
+		database.DB, database.RedisClient = previousDB, previousRedis
+	})
+	return mock, server
+}
+
+// testOwner owns the todos of the tests, which are changed on its behalf by ownerContext
+var testOwner = sql.NullInt64{Int64: 1, Valid: true}
+
+func ownerContext() context.Context {
+	return WithActor(context.Background(), uint(testOwner.Int64))
+}
+
+// todoRows returns todos as rows selected with todoColumns
+func todoRows(todos ...models.TodoList) *sqlmock.Rows {
+	rows := sqlmock.NewRows(strings.Split(todoColumns, ", "))
+	for _, todo := range todos {
+		var dueDate, deletedAt, parentID, ownerID driver.Value
+		if todo.DueDate.Valid {
+			dueDate = todo.DueDate.Time
+		}
+		if todo.DeletedAt.Valid {
+			deletedAt = todo.DeletedAt.Time
+		}
+		if todo.ParentID.Valid {
+			parentID = todo.ParentID.Int64
+		}
+		if todo.OwnerID.Valid {
+			ownerID = todo.OwnerID.Int64
+		}
+		rows.AddRow(todo.ID, todo.Title, todo.Description, todo.Status, dueDate, deletedAt, parentID, todo.Position,
+			boolToNumber(todo.AutoComplete), todo.Recurrence, todo.Occurrence, todo.Version, todo.Priority, ownerID)
+	}
+	return rows
+}
+
+// expectLockTodo expects lockTodo to read todo, or to find nothing when todo is nil
+func expectLockTodo(mock sqlmock.Sqlmock, trashed bool, id string, todo *models.TodoList) {
+	query := "FROM todolist WHERE id = :1 AND deleted_at IS NULL FOR UPDATE"
+	if trashed {
+		query = "FROM todolist WHERE id = :1 AND deleted_at IS NOT NULL FOR UPDATE"
+	}
+	rows := todoRows()
+	if todo != nil {
+		rows = todoRows(*todo)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(id).WillReturnRows(rows)
+}
+
+// expectAudit expects recordAudit to record a change and queue a webhook delivery for each of the events
+func expectAudit(mock sqlmock.Sqlmock, events int) {
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_audit")).WillReturnResult(sqlmock.NewResult(0, 1))
+	for i := 0; i < events; i++ {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_deliveries")).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+}
+
+// publishedEventTypes returns the types of the todo events appended to the resumable stream, oldest first
+func publishedEventTypes(t *testing.T) []string {
+	events, err := ReplayTodoEvents(context.Background(), uint(testOwner.Int64), "0")
+	require.NoError(t, err)
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func newBulkTodo(title string) *models.TodoList {
+	return &models.TodoList{Title: title, Description: "groceries", Status: "pending"}
+}
+
+func TestExecuteBulkPartialRollsBackFailedOperation(t *testing.T) {
+	mock, _ := useMockDB(t)
+	milk := &models.TodoList{ID: 7, Title: "Buy milk", Description: "groceries", Status: "pending", Version: 1, OwnerID: testOwner}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT bulk_item")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todolist")).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, 1)
+	// The delete fails after writing, once its event is already queued
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT bulk_item")).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLockTodo(mock, false, "7", milk)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todolist SET deleted_at = SYSTIMESTAMP")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_audit")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_deliveries")).WillReturnError(errors.New("ORA-01653: unable to extend table"))
+	mock.ExpectExec(regexp.QuoteMeta("ROLLBACK TO SAVEPOINT bulk_item")).WillReturnResult(sqlmock.NewResult(0, 0))
+	// Invalid input is rejected before any statement runs
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT bulk_item")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ROLLBACK TO SAVEPOINT bulk_item")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	results, err := ExecuteBulk(ownerContext(), BulkRequest{Mode: BulkModePartial, Operations: []BulkOperation{
+		{Op: BulkCreate, Todo: newBulkTodo("Buy bread")},
+		{Op: BulkDelete, ID: "7"},
+		{Op: BulkCreate, Todo: newBulkTodo("no")},
+	}})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, fiber.StatusCreated, results[0].Status)
+	assert.Equal(t, fiber.StatusInternalServerError, results[1].Status)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, results[2].Status)
+
+	// Only the events of the operations that were kept are published
+	assert.Equal(t, []string{EventTodoCreated}, publishedEventTypes(t))
+}
+
+func TestExecuteBulkAtomicRollsBackEverything(t *testing.T) {
+	mock, _ := useMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todolist")).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, 1)
+	expectLockTodo(mock, false, "7", nil)
+	mock.ExpectRollback()
+
+	results, err := ExecuteBulk(ownerContext(), BulkRequest{Operations: []BulkOperation{
+		{Op: BulkCreate, Todo: newBulkTodo("Buy bread")},
+		{Op: BulkDelete, ID: "7"},
+		{Op: BulkComplete, ID: "8"},
+	}})
+	assert.ErrorIs(t, err, ErrBulkRolledBack)
+	require.Len(t, results, 3)
+	assert.Equal(t, fiber.StatusFailedDependency, results[0].Status)
+	assert.Nil(t, results[0].Todo)
+	assert.Equal(t, fiber.StatusNotFound, results[1].Status)
+	assert.Equal(t, fiber.StatusFailedDependency, results[2].Status)
+
+	assert.Empty(t, publishedEventTypes(t))
+}
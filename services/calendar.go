@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// FormatICS is the iCalendar format accepted by ImportTodos
+const FormatICS = "ics"
+
+// icsLineLimit is the maximum length in octets of an iCalendar content line before it is folded
+const icsLineLimit = 75
+
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+// CreateCalendarToken generates a new secret token for the calendar feed of a user, replacing any previous one.
+// Only a hash of the token is stored, so it cannot be shown again later.
+func CreateCalendarToken(ctx context.Context, userID uint) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	query := `MERGE INTO calendar_feeds f
+	          USING (SELECT :1 AS user_id, :2 AS token_hash FROM dual) s ON (f.user_id = s.user_id)
+	          WHEN MATCHED THEN UPDATE SET f.token_hash = s.token_hash, f.created_at = SYSTIMESTAMP
+	          WHEN NOT MATCHED THEN INSERT (user_id, token_hash) VALUES (s.user_id, s.token_hash)`
+	if _, err := database.DB.ExecContext(ctx, query, userID, hashCalendarToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// DeleteCalendarToken revokes the calendar feed of a user
+func DeleteCalendarToken(ctx context.Context, userID uint) error {
+	result, err := database.DB.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = :1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrCalendarFeedNotFound
+	}
+	return nil
+}
+
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RenderCalendarFeed writes the todos of the owner of token that have a due date as an iCalendar feed.
+// Each todo becomes a VTODO; with includeEvents it is also shown as an all-day VEVENT on its due date,
+// for calendar apps that do not display tasks.
+func RenderCalendarFeed(ctx context.Context, token string, includeEvents bool) ([]byte, error) {
+	var userID uint
+	query := `SELECT user_id FROM calendar_feeds WHERE token_hash = :1`
+	err := database.DB.QueryRowContext(ctx, query, hashCalendarToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCalendarFeedNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT ` + todoColumns + ` FROM todolist
+	         WHERE owner_id = :1 AND deleted_at IS NULL AND due_date IS NOT NULL ORDER BY due_date, id`
+	rows, err := database.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []models.TodoList
+	for rows.Next() {
+		var todo models.TodoList
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeCalendar(&buf, todos, includeEvents, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCalendar renders todos as an iCalendar object stamped with now
+func writeCalendar(w io.Writer, todos []models.TodoList, includeEvents bool, now time.Time) error {
+	iw := &icsWriter{w: bufio.NewWriter(w)}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//todolist//todos//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	iw.line("X-WR-CALNAME", "Todos")
+
+	for _, todo := range todos {
+		due := todo.DueDate.Time.Format("20060102")
+		rule := calendarRRule(&todo)
+
+		iw.line("BEGIN", "VTODO")
+		iw.line("UID", fmt.Sprintf("todo-%d@todolist", todo.ID))
+		iw.line("DTSTAMP", stamp)
+		iw.line("SUMMARY", escapeICSText(todo.Title))
+		iw.line("DESCRIPTION", escapeICSText(todo.Description))
+		if rule != "" {
+			// RFC 5545 requires DTSTART alongside RRULE; the series starts on the due date
+			iw.line("DTSTART;VALUE=DATE", due)
+		}
+		iw.line("DUE;VALUE=DATE", due)
+		if todo.Status == "completed" {
+			iw.line("STATUS", "COMPLETED")
+		} else {
+			iw.line("STATUS", "NEEDS-ACTION")
+		}
+		if todo.Priority != "" {
+			iw.line("PRIORITY", strconv.Itoa(calendarPriority(todo.Priority)))
+		}
+		if rule != "" {
+			iw.line("RRULE", rule)
+		}
+		iw.line("END", "VTODO")
+
+		if includeEvents {
+			iw.line("BEGIN", "VEVENT")
+			iw.line("UID", fmt.Sprintf("event-%d@todolist", todo.ID))
+			iw.line("DTSTAMP", stamp)
+			iw.line("SUMMARY", escapeICSText(todo.Title))
+			iw.line("DESCRIPTION", escapeICSText(todo.Description))
+			iw.line("DTSTART;VALUE=DATE", due)
+			iw.line("DTEND;VALUE=DATE", todo.DueDate.Time.AddDate(0, 0, 1).Format("20060102"))
+			iw.line("TRANSP", "TRANSPARENT")
+			if rule != "" {
+				iw.line("RRULE", rule)
+			}
+			iw.line("END", "VEVENT")
+		}
+	}
+
+	iw.line("END", "VCALENDAR")
+	return iw.flush()
+}
+
+// calendarRRule returns the recurrence of a pending todo as seen from its own occurrence: every occurrence is
+// a separate todo, so completed ones carry no rule and COUNT only covers the occurrences still to come.
+// Components start on a date, so UNTIL is a date too.
+func calendarRRule(todo *models.TodoList) string {
+	if todo.Recurrence == "" || todo.Status == "completed" {
+		return ""
+	}
+
+	rule, err := ParseRRule(todo.Recurrence)
+	if err != nil {
+		return ""
+	}
+	if rule.Count > 0 {
+		rule.Count -= todo.Occurrence - 1
+		if rule.Count < 1 {
+			return ""
+		}
+	}
+	return rule.DateString()
+}
+
+// calendarPriority maps a todo priority letter to an iCalendar PRIORITY, where 1 is the highest and 9 the lowest
+func calendarPriority(priority string) int {
+	return min(int(priority[0]-'A')+1, 9)
+}
+
+// icsWriter writes folded iCalendar content lines, keeping the first error
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (iw *icsWriter) line(name, value string) {
+	if iw.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+	// Continuation lines start with a space, which counts towards their limit
+	limit := icsLineLimit
+	for len(line) > limit {
+		// Fold before the limit without splitting a multi-byte character
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, iw.err = iw.w.WriteString(line[:cut] + "\r\n "); iw.err != nil {
+			return
+		}
+		line = line[cut:]
+		limit = icsLineLimit - 1
+	}
+	_, iw.err = iw.w.WriteString(line + "\r\n")
+}
+
+func (iw *icsWriter) flush() error {
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+func escapeICSText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(value)
+}
+
+func unescapeICSText(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
+
+// readICSRecords reads the VTODO components of an iCalendar object as import records.
+// A VTODO without a description uses its summary, since todos require both.
+func readICSRecords(data []byte) ([]map[string]string, error) {
+	// Unfold continuation lines before splitting the content into properties
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	content = strings.NewReplacer("\n ", "", "\n\t", "").Replace(content)
+
+	var records []map[string]string
+	var components []string
+	var record map[string]string
+
+	for i, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		nameAndParams, value, ok := cutICSProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid content line", i+1)
+		}
+		params := strings.Split(nameAndParams, ";")
+		name := strings.ToUpper(params[0])
+
+		switch name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(value))
+			if strings.EqualFold(value, "VTODO") {
+				record = map[string]string{"status": "pending"}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, value)
+			}
+			components = components[:len(components)-1]
+			if strings.EqualFold(value, "VTODO") {
+				if record["description"] == "" {
+					record["description"] = record["title"]
+				}
+				records = append(records, record)
+				record = nil
+				if len(records) > MaxImportRows {
+					return records, nil
+				}
+			}
+			continue
+		}
+
+		// Only read the properties of the VTODO itself, not of nested components such as VALARM
+		if record == nil || components[len(components)-1] != "VTODO" {
+			continue
+		}
+
+		switch name {
+		case "SUMMARY":
+			record["title"] = unescapeICSText(value)
+		case "DESCRIPTION":
+			record["description"] = unescapeICSText(value)
+		case "STATUS":
+			if strings.EqualFold(value, "COMPLETED") {
+				record["status"] = "completed"
+			}
+		case "DUE":
+			if len(value) < 8 {
+				return nil, fmt.Errorf("line %d: invalid DUE %q", i+1, value)
+			}
+			due, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DUE %q", i+1, value)
+			}
+			record["due_date"] = due.Format("2006-01-02")
+		case "RRULE":
+			record["recurrence"] = value
+		case "PRIORITY":
+			// 0 means undefined; 1 to 9 map to the letters A to I
+			if priority, err := strconv.Atoi(value); err == nil && priority >= 1 && priority <= 9 {
+				record["priority"] = string(rune('A' + priority - 1))
+			}
+		}
+	}
+
+	if len(components) > 0 {
+		return nil, fmt.Errorf("unterminated %s component", components[len(components)-1])
+	}
+	return records, nil
+}
+
+// cutICSProperty splits a content line into its name with parameters and its value,
+// ignoring colons inside quoted parameter values
+func cutICSProperty(line string) (string, string, bool) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ':' && !quoted:
+			return line[:i], line[i+1:], true
+		}
+	}
+	return "", "", false
+}
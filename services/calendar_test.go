@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	todos := []models.TodoList{
+		{ID: 1, Title: "Pay rent, again", Description: "Line one\nLine two", Status: "pending", Priority: "B",
+			DueDate: sql.NullTime{Time: due, Valid: true}, Recurrence: "FREQ=MONTHLY;COUNT=12", Occurrence: 3},
+		{ID: 2, Title: "Done", Description: strings.Repeat("x", 200), Status: "completed",
+			DueDate: sql.NullTime{Time: due, Valid: true}, Recurrence: "FREQ=DAILY"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCalendar(&buf, todos, true, due))
+	ics := buf.String()
+
+	assert.Contains(t, ics, "BEGIN:VCALENDAR\r\n")
+	assert.Contains(t, ics, "SUMMARY:Pay rent\\, again\r\n")
+	assert.Contains(t, ics, "DESCRIPTION:Line one\\nLine two\r\n")
+	assert.Contains(t, ics, "DUE;VALUE=DATE:20240501\r\n")
+	assert.Contains(t, ics, "RRULE:FREQ=MONTHLY;COUNT=10\r\n")
+	assert.Contains(t, ics, "STATUS:COMPLETED\r\n")
+	assert.Contains(t, ics, "PRIORITY:2\r\n")
+	assert.Contains(t, ics, "DTEND;VALUE=DATE:20240502\r\n")
+	assert.Equal(t, 2, strings.Count(ics, "RRULE:FREQ=MONTHLY"), "the event repeats the rule of the todo")
+	assert.NotContains(t, ics, "FREQ=DAILY", "completed occurrences carry no rule")
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), icsLineLimit)
+	}
+}
+
+func TestWriteCalendarStartsRecurringComponentsOnDueDate(t *testing.T) {
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	todos := []models.TodoList{
+		{ID: 7, Title: "Water plants", Status: "pending", DueDate: sql.NullTime{Time: due, Valid: true},
+			Recurrence: "FREQ=WEEKLY;UNTIL=20240630T120000Z", Occurrence: 1},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCalendar(&buf, todos, true, due))
+
+	assert.Equal(t, "BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"PRODID:-//todolist//todos//EN\r\n"+
+		"CALSCALE:GREGORIAN\r\n"+
+		"X-WR-CALNAME:Todos\r\n"+
+		"BEGIN:VTODO\r\n"+
+		"UID:todo-7@todolist\r\n"+
+		"DTSTAMP:20240501T000000Z\r\n"+
+		"SUMMARY:Water plants\r\n"+
+		"DESCRIPTION:\r\n"+
+		"DTSTART;VALUE=DATE:20240501\r\n"+
+		"DUE;VALUE=DATE:20240501\r\n"+
+		"STATUS:NEEDS-ACTION\r\n"+
+		"RRULE:FREQ=WEEKLY;UNTIL=20240630\r\n"+
+		"END:VTODO\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:event-7@todolist\r\n"+
+		"DTSTAMP:20240501T000000Z\r\n"+
+		"SUMMARY:Water plants\r\n"+
+		"DESCRIPTION:\r\n"+
+		"DTSTART;VALUE=DATE:20240501\r\n"+
+		"DTEND;VALUE=DATE:20240502\r\n"+
+		"TRANSP:TRANSPARENT\r\n"+
+		"RRULE:FREQ=WEEKLY;UNTIL=20240630\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n", buf.String())
+}
+
+func TestReadICSRecords(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY:Water the\r\n  plants\r\n" +
+		"DUE;TZID=\"Europe/Berlin\":20240501T090000\r\n" +
+		"RRULE:FREQ=WEEKLY\r\n" +
+		"PRIORITY:1\r\n" +
+		"BEGIN:VALARM\r\nDESCRIPTION:Alarm\r\nEND:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\nSUMMARY:Done\\, really\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	records, err := readICSRecords([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, map[string]string{
+		"title": "Water the plants", "description": "Water the plants", "status": "pending",
+		"due_date": "2024-05-01", "recurrence": "FREQ=WEEKLY", "priority": "A",
+	}, records[0])
+	assert.Equal(t, "Done, really", records[1]["title"])
+	assert.Equal(t, "completed", records[1]["status"])
+
+	_, err = readICSRecords([]byte("BEGIN:VTODO\r\nSUMMARY:Unterminated\r\n"))
+	assert.Error(t, err)
+}
+
+func TestRenderCalendarFeedOnlyListsTodosOfTokenOwner(t *testing.T) {
+	mock, _ := useMockDB(t)
+	due := sql.NullTime{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM calendar_feeds WHERE token_hash = :1")).
+		WithArgs(hashCalendarToken("secret-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(5))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE owner_id = :1 AND deleted_at IS NULL AND due_date IS NOT NULL")).
+		WithArgs(uint(5)).
+		WillReturnRows(todoRows(models.TodoList{ID: 1, Title: "Pay rent", Description: "flat", Status: "pending", DueDate: due,
+			OwnerID: sql.NullInt64{Int64: 5, Valid: true}}))
+
+	ics, err := RenderCalendarFeed(context.Background(), "secret-token", false)
+	require.NoError(t, err)
+	assert.Contains(t, string(ics), "SUMMARY:Pay rent\r\n")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM calendar_feeds")).WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	_, err = RenderCalendarFeed(context.Background(), "revoked-token", false)
+	assert.ErrorIs(t, err, ErrCalendarFeedNotFound)
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"todolist/database"
+	"todolist/models"
+)
+
+const (
+	// todoEventsStream keeps recent events so clients can resume from the last event they received
+	todoEventsStream = "todos:events:stream"
+	// todoEventsChannel fans events out to the stream subscribers of every server instance
+	todoEventsChannel = "todos:events"
+	// todoEventsRetention is the approximate number of events kept for resuming
+	todoEventsRetention = 10000
+	// MaxReplayedEvents caps the number of missed events replayed to a resuming client
+	MaxReplayedEvents = 1000
+	// subscriberBuffer is the number of events buffered per subscriber before it is considered too slow
+	subscriberBuffer = 64
+)
+
+// Todo events pushed to streaming clients and delivered to webhooks
+const (
+	EventTodoCreated   = "todo.created"
+	EventTodoUpdated   = "todo.updated"
+	EventTodoCompleted = "todo.completed"
+	EventTodoDeleted   = "todo.deleted"
+	EventTodoRestored  = "todo.restored"
+)
+
+// TodoEvent is a change to a todo pushed to streaming clients
+type TodoEvent struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	ActorID    *uint            `json:"actor_id"`
+	Todo       *models.TodoList `json:"todo"`
+}
+
+// visibleTo reports whether the event concerns a todo owned by the user, the only one allowed to receive it
+func (e TodoEvent) visibleTo(userID uint) bool {
+	return e.Todo != nil && e.Todo.OwnerID.Valid && e.Todo.OwnerID.Int64 == int64(userID)
+}
+
+// todoEventsFor returns the events triggered by an audited todo change
+func todoEventsFor(operation string, before, after *models.TodoList) []string {
+	switch operation {
+	case AuditCreate:
+		return []string{EventTodoCreated}
+	case AuditUpdate:
+		events := []string{EventTodoUpdated}
+		if before != nil && after != nil && before.Status != "completed" && after.Status == "completed" {
+			events = append(events, EventTodoCompleted)
+		}
+		return events
+	case AuditDelete:
+		return []string{EventTodoDeleted}
+	case AuditRestore:
+		return []string{EventTodoRestored}
+	default:
+		return nil
+	}
+}
+
+// pendingEvents collects the events of a transaction so they are only published once it commits
+type pendingEvents struct {
+	events []TodoEvent
+}
+
+type pendingEventsKey struct{}
+
+// withPendingEvents returns a copy of ctx that collects the todo events recorded with it
+func withPendingEvents(ctx context.Context) (context.Context, *pendingEvents) {
+	pending := &pendingEvents{}
+	return context.WithValue(ctx, pendingEventsKey{}, pending), pending
+}
+
+// queueTodoEvents adds the events triggered by a todo change to the events pending in ctx, if any
+func queueTodoEvents(ctx context.Context, operation string, before, after *models.TodoList) {
+	pending, ok := ctx.Value(pendingEventsKey{}).(*pendingEvents)
+	if !ok {
+		return
+	}
+
+	todo := after
+	if todo == nil {
+		todo = before
+	}
+	var actorID *uint
+	if userID, ok := ActorFromContext(ctx); ok {
+		actorID = &userID
+	}
+
+	for _, eventType := range todoEventsFor(operation, before, after) {
+		pending.events = append(pending.events, TodoEvent{Type: eventType, OccurredAt: time.Now().UTC(), ActorID: actorID, Todo: todo})
+	}
+}
+
+// mark returns a position that truncate can roll the pending events back to
+func (p *pendingEvents) mark() int {
+	return len(p.events)
+}
+
+func (p *pendingEvents) truncate(mark int) {
+	p.events = p.events[:mark]
+}
+
+// publish appends the pending events to the resumable stream and fans them out to every server instance.
+// Publishing is best effort: the changes are already committed, so failures are only logged.
+func (p *pendingEvents) publish(ctx context.Context) {
+	for _, event := range p.events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to encode todo event", "error", err)
+			continue
+		}
+
+		event.ID, err = database.RedisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: todoEventsStream,
+			MaxLen: todoEventsRetention,
+			Approx: true,
+			Values: map[string]interface{}{"event": data},
+		}).Result()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to store todo event", "error", err)
+			continue
+		}
+
+		if data, err = json.Marshal(event); err == nil {
+			err = database.RedisClient.Publish(ctx, todoEventsChannel, data).Err()
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to publish todo event", "error", err)
+		}
+	}
+	p.events = nil
+}
+
+// ReplayTodoEvents returns the retained events about the todos of a user that came after the event with ID
+// lastEventID, oldest first
+func ReplayTodoEvents(ctx context.Context, userID uint, lastEventID string) ([]TodoEvent, error) {
+	messages, err := database.RedisClient.XRangeN(ctx, todoEventsStream, "("+lastEventID, "+", MaxReplayedEvents).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]TodoEvent, 0, len(messages))
+	for _, message := range messages {
+		data, _ := message.Values["event"].(string)
+
+		var event TodoEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, err
+		}
+		event.ID = message.ID
+		if event.visibleTo(userID) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// CompareEventIDs compares two Redis stream IDs ("<milliseconds>-<sequence>"), returning -1, 0 or 1
+func CompareEventIDs(a, b string) int {
+	aMillis, aSeq := splitEventID(a)
+	bMillis, bSeq := splitEventID(b)
+	switch {
+	case aMillis < bMillis || (aMillis == bMillis && aSeq < bSeq):
+		return -1
+	case aMillis == bMillis && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func splitEventID(id string) (uint64, uint64) {
+	millis, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(millis, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
+
+// todoEventHub relays the events published on todoEventsChannel to the subscribers of this server instance
+type todoEventHub struct {
+	once sync.Once
+	mu   sync.Mutex
+	// subscribers maps the channel of each subscriber to the user it receives the events of
+	subscribers map[chan TodoEvent]uint
+}
+
+var eventHub = &todoEventHub{subscribers: make(map[chan TodoEvent]uint)}
+
+// SubscribeTodoEvents returns a channel receiving the events about the todos of a user published from now on,
+// and a function to unsubscribe. The channel is closed when the subscriber falls too far behind; it should
+// then reconnect and resume from the last event it received.
+func SubscribeTodoEvents(userID uint) (<-chan TodoEvent, func()) {
+	eventHub.once.Do(func() { go eventHub.run() })
+
+	ch := make(chan TodoEvent, subscriberBuffer)
+	eventHub.mu.Lock()
+	eventHub.subscribers[ch] = userID
+	eventHub.mu.Unlock()
+
+	return ch, func() { eventHub.remove(ch) }
+}
+
+func (h *todoEventHub) run() {
+	pubsub := database.RedisClient.Subscribe(context.Background(), todoEventsChannel)
+	defer pubsub.Close()
+
+	for message := range pubsub.Channel() {
+		var event TodoEvent
+		if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+			slog.Error("Failed to decode todo event", "error", err)
+			continue
+		}
+		h.broadcast(event)
+	}
+}
+
+func (h *todoEventHub) broadcast(event TodoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch, userID := range h.subscribers {
+		if !event.visibleTo(userID) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *todoEventHub) remove(ch chan TodoEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func ownedTodo(id int, ownerID int64) *models.TodoList {
+	return &models.TodoList{ID: id, Title: "Buy milk", Status: "pending", OwnerID: sql.NullInt64{Int64: ownerID, Valid: ownerID != 0}}
+}
+
+func TestTodoEventHubOnlyDeliversEventsOfOwnTodos(t *testing.T) {
+	hub := &todoEventHub{subscribers: make(map[chan TodoEvent]uint)}
+	mine, theirs := make(chan TodoEvent, 4), make(chan TodoEvent, 4)
+	hub.subscribers[mine] = 1
+	hub.subscribers[theirs] = 2
+
+	hub.broadcast(TodoEvent{ID: "1-0", Type: EventTodoCreated, Todo: ownedTodo(7, 1)})
+	hub.broadcast(TodoEvent{ID: "2-0", Type: EventTodoCreated, Todo: ownedTodo(8, 0)})
+
+	require.Len(t, mine, 1)
+	assert.Equal(t, 7, (<-mine).Todo.ID)
+	assert.Empty(t, theirs)
+}
+
+func TestReplayTodoEventsOnlyReturnsEventsOfOwnTodos(t *testing.T) {
+	useMockDB(t)
+	pending := &pendingEvents{events: []TodoEvent{
+		{Type: EventTodoCreated, Todo: ownedTodo(7, 1)},
+		{Type: EventTodoCreated, Todo: ownedTodo(8, 2)},
+		{Type: EventTodoDeleted, Todo: ownedTodo(7, 1)},
+	}}
+	pending.publish(context.Background())
+
+	events, err := ReplayTodoEvents(context.Background(), 1, "0")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, EventTodoCreated, events[0].Type)
+	assert.Equal(t, EventTodoDeleted, events[1].Type)
+
+	events, err = ReplayTodoEvents(context.Background(), 3, "0")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
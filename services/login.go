@@ -1,7 +1,10 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -9,11 +12,44 @@ import (
 	"time"
 	"todolist/database"
 	"todolist/helper"
+	"todolist/metrics"
 	"todolist/models"
 )
 
 var jwtSecret = os.Getenv("API_KEY")
 
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+// Authenticate checks the credentials of a user and returns a signed JWT for them
+func Authenticate(ctx context.Context, username, password string) (token string, err error) {
+	defer func() { metrics.Login(err == nil) }()
+
+	var user models.User
+	// Query the user by username
+	err = database.DB.QueryRowContext(ctx, "SELECT id, username, password, role FROM users WHERE username = :1", username).
+		Scan(&user.ID, &user.Username, &user.Password, &user.Role)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+
+	// Check if the password is correct
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return "", ErrInvalidPassword
+	}
+
+	// Generate JWT token
+	token, err = generateJwt(user.ID, user.Username, user.Role)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate JWT: %w", err)
+	}
+	return token, nil
+}
+
 func Login(c *fiber.Ctx) error {
 	type LoginInput struct {
 		Username string `json:"username"`
@@ -26,28 +62,16 @@ func Login(c *fiber.Ctx) error {
 		return err
 	}
 
-	var user models.User
-	// Query the user by username
-	err := database.DB.QueryRow("SELECT id, username, password FROM users WHERE username = :1", input.Username).
-		Scan(&user.ID, &user.Username, &user.Password)
-	if err == sql.ErrNoRows {
+	token, err := Authenticate(c.UserContext(), input.Username, input.Password)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
 		helper.RespondJSON(c, fiber.StatusNotFound, "User not found", nil, nil)
 		return nil
-	} else if err != nil {
-		helper.RespondJSON(c, fiber.StatusInternalServerError, "Database error", nil, err.Error())
-		return err
-	}
-
-	// Check if the password is correct
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+	case errors.Is(err, ErrInvalidPassword):
 		helper.RespondJSON(c, fiber.StatusUnauthorized, "Invalid password", nil, nil)
 		return nil
-	}
-
-	// Generate JWT token
-	token, err := generateJwt(user.ID, user.Username)
-	if err != nil {
-		helper.RespondJSON(c, fiber.StatusInternalServerError, "Failed to generate JWT", nil, err.Error())
+	case err != nil:
+		helper.RespondJSON(c, fiber.StatusInternalServerError, "Login failed", nil, err.Error())
 		return err
 	}
 
@@ -56,10 +80,11 @@ func Login(c *fiber.Ctx) error {
 	return nil
 }
 
-func generateJwt(id uint, username string) (string, error) {
+func generateJwt(id uint, username, role string) (string, error) {
 	claims := jwt.MapClaims{
 		"userId":   id,
 		"username": username,
+		"role":     role,
 		"exp":      time.Now().Add(time.Hour * 72).Unix(),
 	}
 
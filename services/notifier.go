@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"todolist/models"
+)
+
+// Notification is what a Notifier delivers when a reminder fires
+type Notification struct {
+	// Key is stable across redeliveries of the same reminder so receivers can drop duplicates
+	Key      string          `json:"key"`
+	Reminder models.Reminder `json:"reminder"`
+	Todo     models.TodoList `json:"todo"`
+}
+
+// Notifier delivers notifications over a single channel such as email or a webhook
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = make(map[string]Notifier)
+)
+
+// RegisterNotifier makes a notification channel available to reminders under the given name
+func RegisterNotifier(channel string, notifier Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers[channel] = notifier
+}
+
+func getNotifier(channel string) (Notifier, bool) {
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+	notifier, ok := notifiers[channel]
+	return notifier, ok
+}
+
+// defaultEmailTimeout bounds an email delivery when EmailNotifier.Timeout is not set. It is well below
+// reminderLease, so a stalled SMTP server cannot keep a reminder until another instance claims it again.
+const defaultEmailTimeout = 30 * time.Second
+
+// EmailNotifier sends reminders as plain text emails through an SMTP server, addressed to the reminder target
+type EmailNotifier struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+	// Timeout bounds connecting to the server and the whole exchange with it, along with the deadline of ctx
+	Timeout time.Duration
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	todo := notification.Todo
+	subject := fmt.Sprintf("Reminder: %s", todo.Title)
+	body := fmt.Sprintf("%s\r\n\r\n%s\r\n\r\nDue: %s\r\n", todo.Title, todo.Description, todo.DueDate.Time.Format(time.RFC1123))
+
+	message := strings.Join([]string{
+		"From: " + headerValue(n.From),
+		"To: " + headerValue(notification.Reminder.Target),
+		"Subject: " + headerValue(subject),
+		// Mail clients collapse duplicates sharing a Message-ID
+		fmt.Sprintf("Message-ID: <%s@todolist>", notification.Key),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return n.send(ctx, notification.Reminder.Target, []byte(message))
+}
+
+// send delivers message to recipient as smtp.SendMail does, giving up once ctx is done or the timeout of
+// the notifier has passed
+func (n *EmailNotifier) send(ctx context.Context, recipient string, message []byte) error {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmailTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	// Cancelling ctx interrupts the exchange, as the deadline does
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(n.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(n.Auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(recipient); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// headerValue strips line breaks so user-provided text cannot inject extra mail headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// WebhookNotifier posts reminders as JSON to the URL in the reminder target
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Reminder.Target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", notification.Key)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+// fakeSMTP accepts a single connection and answers it with respond, returning the address it listens on
+func fakeSMTP(t *testing.T, respond func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		respond(conn)
+	}()
+	return listener.Addr().String()
+}
+
+func emailNotification() Notification {
+	return Notification{
+		Key:      "reminder-1-0",
+		Reminder: models.Reminder{ID: 1, Channel: ChannelEmail, Target: "ada@example.com"},
+		Todo:     models.TodoList{ID: 1, Title: "Pay rent"},
+	}
+}
+
+func TestEmailNotifierSendsMail(t *testing.T) {
+	received := make(chan string, 1)
+	addr := fakeSMTP(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				received <- data.String()
+				conn.Write([]byte("250 queued\r\n"))
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				conn.Write([]byte("354 go ahead\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	})
+
+	notifier := &EmailNotifier{Addr: addr, From: "todolist@example.com", Timeout: 5 * time.Second}
+	require.NoError(t, notifier.Notify(context.Background(), emailNotification()))
+	assert.Contains(t, <-received, "Subject: Reminder: Pay rent\r\n")
+}
+
+func TestEmailNotifierGivesUpOnStalledServer(t *testing.T) {
+	// The server accepts the connection but never greets the client
+	addr := fakeSMTP(t, func(conn net.Conn) { time.Sleep(5 * time.Second) })
+
+	notifier := &EmailNotifier{Addr: addr, From: "todolist@example.com", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	assert.Error(t, notifier.Notify(context.Background(), emailNotification()))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// Cancelling the context ends the exchange as well
+	addr = fakeSMTP(t, func(conn net.Conn) { time.Sleep(5 * time.Second) })
+	notifier = &EmailNotifier{Addr: addr, From: "todolist@example.com", Timeout: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.Error(t, notifier.Notify(ctx, emailNotification()))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenDestination is returned for webhook and reminder URLs that lead to an address of the server's
+// own network, such as loopback, private or link-local addresses
+var ErrForbiddenDestination = errors.New("destination address is not allowed")
+
+// allowedDestination reports whether requests to user-supplied URLs may connect to addr. Refusing internal
+// addresses keeps webhooks from being used to reach services that are not exposed, like cloud metadata.
+func allowedDestination(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast()
+}
+
+// CheckDestination resolves the host of rawURL and returns ErrForbiddenDestination unless every address it
+// resolves to is allowed. It is run when a URL is registered; NewOutboundClient checks again on every
+// connection since the host may resolve to another address by then.
+func CheckDestination(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve: %v", ErrForbiddenDestination, u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !allowedDestination(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenDestination, u.Hostname(), addr)
+		}
+	}
+	return nil
+}
+
+// NewOutboundClient returns the HTTP client used to call user-supplied URLs. It refuses to connect to
+// addresses that are not allowed, which are checked once the host is resolved so redirects and DNS
+// changes cannot lead it inside the network. Proxies are not used, as they would hide the destination.
+func NewOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowedDestination(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenDestination, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
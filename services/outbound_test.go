@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDestination(t *testing.T) {
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://[fd00::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		assert.ErrorIs(t, CheckDestination(context.Background(), rawURL), ErrForbiddenDestination, rawURL)
+	}
+
+	assert.NoError(t, CheckDestination(context.Background(), "https://93.184.216.34/hook"))
+}
+
+func TestOutboundClientRefusesInternalAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	_, err := NewOutboundClient(time.Second).Get(receiver.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrForbiddenDestination)
+}
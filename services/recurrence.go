@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"todolist/models"
+)
+
+// Recurrence frequencies supported from RFC 5545
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// MaxPreviewOccurrences caps the number of upcoming occurrences returned by GetUpcomingOccurrences
+const MaxPreviewOccurrences = 100
+
+// maxRecurrenceSearch bounds the number of periods scanned when looking for the next occurrence,
+// so that rules which can never match (e.g. the 5th Monday every 12 months) terminate
+const maxRecurrenceSearch = 1000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum is a BYDAY entry such as MO, 1MO (first Monday) or -1FR (last Friday)
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// RRule is the subset of an RFC 5545 recurrence rule supported for todos:
+// FREQ, INTERVAL, BYDAY, COUNT and UNTIL
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	Until    time.Time
+}
+
+// ParseRRule parses a recurrence rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10".
+// An optional "RRULE:" prefix is accepted.
+func ParseRRule(rule string) (*RRule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	r := &RRule{Interval: 1}
+
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != FreqDaily && r.Freq != FreqWeekly && r.Freq != FreqMonthly && r.Freq != FreqYearly {
+				return nil, fmt.Errorf("unsupported recurrence frequency %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid recurrence interval %q", value)
+			}
+			r.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid recurrence count %q", value)
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recurrence until %q", value)
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekdayNum, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, weekdayNum)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("recurrence rule requires FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("recurrence rule cannot have both COUNT and UNTIL")
+	}
+	for _, day := range r.ByDay {
+		if day.Ordinal != 0 && r.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported with FREQ=MONTHLY")
+		}
+	}
+	if len(r.ByDay) > 0 && r.Freq == FreqYearly {
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=YEARLY")
+	}
+
+	return r, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// A date-only UNTIL includes the whole day
+	return t.Add(24*time.Hour - time.Nanosecond), nil
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid recurrence weekday %q", value)
+	}
+
+	weekday, ok := weekdays[value[len(value)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid recurrence weekday %q", value)
+	}
+
+	var ordinal int
+	if prefix := value[:len(value)-2]; prefix != "" {
+		var err error
+		ordinal, err = strconv.Atoi(prefix)
+		if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid recurrence weekday %q", value)
+		}
+	}
+
+	return WeekdayNum{Ordinal: ordinal, Weekday: weekday}, nil
+}
+
+// String formats the rule back into its RFC 5545 form, without the "RRULE:" prefix
+func (r *RRule) String() string {
+	return r.format("20060102T150405Z")
+}
+
+// DateString formats the rule like String, but with UNTIL as a date, as RFC 5545 requires for rules whose
+// DTSTART is a date
+func (r *RRule) DateString() string {
+	return r.format("20060102")
+}
+
+func (r *RRule) format(untilLayout string) string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = strings.ToUpper(day.Weekday.String()[:2])
+			if day.Ordinal != 0 {
+				days[i] = strconv.Itoa(day.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the occurrence following current, which is the given 1-based occurrence of the series.
+// It returns false once the series is over because of COUNT or UNTIL.
+func (r *RRule) Next(current time.Time, occurrence int) (time.Time, bool) {
+	if r.Count > 0 && occurrence >= r.Count {
+		return time.Time{}, false
+	}
+
+	next, ok := r.next(current)
+	if !ok || (!r.Until.IsZero() && next.After(r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Occurrences returns up to n occurrences following current, which is the given occurrence of the series
+func (r *RRule) Occurrences(current time.Time, occurrence, n int) []time.Time {
+	var occurrences []time.Time
+	for len(occurrences) < n {
+		next, ok := r.Next(current, occurrence)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+		current, occurrence = next, occurrence+1
+	}
+	return occurrences
+}
+
+func (r *RRule) next(current time.Time) (time.Time, bool) {
+	switch r.Freq {
+	case FreqDaily:
+		for i := 1; i <= maxRecurrenceSearch; i++ {
+			candidate := current.AddDate(0, 0, i*r.Interval)
+			if len(r.ByDay) == 0 || r.matchesWeekday(candidate) {
+				return candidate, true
+			}
+		}
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return current.AddDate(0, 0, 7*r.Interval), true
+		}
+		// Remaining days of the current week first, then the first matching day of each following period
+		weekStart := current.AddDate(0, 0, -mondayOffset(current))
+		for period := 0; period <= maxRecurrenceSearch; period += r.Interval {
+			for day := 0; day < 7; day++ {
+				candidate := weekStart.AddDate(0, 0, period*7+day)
+				if candidate.After(current) && r.matchesWeekday(candidate) {
+					return candidate, true
+				}
+			}
+		}
+	case FreqMonthly:
+		monthStart := time.Date(current.Year(), current.Month(), 1, current.Hour(), current.Minute(), current.Second(), 0, current.Location())
+		for period := 0; period <= maxRecurrenceSearch; period += r.Interval {
+			month := monthStart.AddDate(0, period, 0)
+			for _, candidate := range r.monthlyCandidates(month, current.Day()) {
+				if candidate.After(current) {
+					return candidate, true
+				}
+			}
+		}
+	case FreqYearly:
+		for period := r.Interval; period <= maxRecurrenceSearch; period += r.Interval {
+			candidate := time.Date(current.Year()+period, current.Month(), current.Day(), current.Hour(), current.Minute(), current.Second(), 0, current.Location())
+			// Skip years where the date does not exist, such as February 29th
+			if candidate.Day() == current.Day() {
+				return candidate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// monthlyCandidates returns the occurrences within the month starting at monthStart, in chronological order
+func (r *RRule) monthlyCandidates(monthStart time.Time, day int) []time.Time {
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	if len(r.ByDay) == 0 {
+		// Months without the day of the anchor date are skipped, as RFC 5545 requires
+		if day > daysInMonth {
+			return nil
+		}
+		return []time.Time{monthStart.AddDate(0, 0, day-1)}
+	}
+
+	var candidates []time.Time
+	for d := 1; d <= daysInMonth; d++ {
+		candidate := monthStart.AddDate(0, 0, d-1)
+		for _, byDay := range r.ByDay {
+			if candidate.Weekday() != byDay.Weekday {
+				continue
+			}
+			fromStart := (d-1)/7 + 1
+			fromEnd := -((daysInMonth-d)/7 + 1)
+			if byDay.Ordinal == 0 || byDay.Ordinal == fromStart || byDay.Ordinal == fromEnd {
+				candidates = append(candidates, candidate)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+func (r *RRule) matchesWeekday(t time.Time) bool {
+	for _, day := range r.ByDay {
+		if t.Weekday() == day.Weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// mondayOffset returns the number of days since the Monday starting the week of t
+func mondayOffset(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// validateRecurrence checks that the recurrence rule of todo, if any, is valid and anchored on a due date
+func validateRecurrence(todo *models.TodoList) error {
+	if todo.Recurrence == "" {
+		return nil
+	}
+
+	rule, err := ParseRRule(todo.Recurrence)
+	if err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+	if !todo.DueDate.Valid {
+		return fmt.Errorf("validation error: a recurring todo requires a due date")
+	}
+
+	todo.Recurrence = rule.String()
+	return nil
+}
+
+// createNextOccurrence inserts the next occurrence of a recurring todo item that has just been completed within tx.
+// Nothing is created when the series is over or the next occurrence already exists.
+func createNextOccurrence(ctx context.Context, tx *sql.Tx, todo *models.TodoList) error {
+	if todo.Recurrence == "" || !todo.DueDate.Valid {
+		return nil
+	}
+
+	rule, err := ParseRRule(todo.Recurrence)
+	if err != nil {
+		return err
+	}
+	due, ok := rule.Next(todo.DueDate.Time, todo.Occurrence)
+	if !ok {
+		return nil
+	}
+
+	// A todo reopened and completed again must not spawn a second copy of its next occurrence
+	var existing int
+	query := `SELECT COUNT(*) FROM todolist WHERE previous_id = :1`
+	if err := tx.QueryRowContext(ctx, query, todo.ID).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	next := models.TodoList{
+		Title:        todo.Title,
+		Description:  todo.Description,
+		Status:       "pending",
+		DueDate:      sql.NullTime{Time: due, Valid: true},
+		ParentID:     todo.ParentID,
+		Position:     todo.Position,
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   todo.Recurrence,
+		Priority:     todo.Priority,
+		Occurrence:   todo.Occurrence + 1,
+		// The next occurrence stays with the owner, whoever completed this one
+		OwnerID: todo.OwnerID,
+	}
+	if err := insertTodoTx(ctx, tx, &next); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE todolist SET previous_id = :1 WHERE id = :2`, todo.ID, next.ID)
+	if err != nil {
+		return err
+	}
+
+	return copyReminders(ctx, tx, todo.ID, next.ID, due)
+}
+
+// GetUpcomingOccurrences previews up to n due dates following the current occurrence of a recurring todo item
+func GetUpcomingOccurrences(ctx context.Context, id string, n int) ([]time.Time, error) {
+	todo, err := GetTodoByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if todo == nil {
+		return nil, ErrTodoNotFound
+	}
+	if todo.Recurrence == "" || !todo.DueDate.Valid {
+		return []time.Time{}, nil
+	}
+
+	rule, err := ParseRRule(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := rule.Occurrences(todo.DueDate.Time, todo.Occurrence, min(n, MaxPreviewOccurrences))
+	if occurrences == nil {
+		occurrences = []time.Time{}
+	}
+	return occurrences, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRRule(t *testing.T) {
+	rule, err := ParseRRule("RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10")
+	require.NoError(t, err)
+	assert.Equal(t, FreqWeekly, rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.Equal(t, []WeekdayNum{{Weekday: time.Monday}, {Weekday: time.Friday}}, rule.ByDay)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10", rule.String())
+
+	for _, invalid := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		_, err := ParseRRule(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRRuleOccurrences(t *testing.T) {
+	tests := []struct {
+		rule     string
+		start    time.Time
+		limit    int
+		expected []time.Time
+	}{
+		{"FREQ=DAILY;INTERVAL=3", date(2024, 1, 30), 2, []time.Time{date(2024, 2, 2), date(2024, 2, 5)}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", date(2024, 11, 8), 2, []time.Time{date(2024, 11, 11), date(2024, 11, 12)}},
+		// 2024-11-06 is a Wednesday
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", date(2024, 11, 6), 3, []time.Time{date(2024, 11, 8), date(2024, 11, 18), date(2024, 11, 20)}},
+		{"FREQ=MONTHLY", date(2024, 1, 31), 2, []time.Time{date(2024, 3, 31), date(2024, 5, 31)}},
+		{"FREQ=MONTHLY;BYDAY=-1FR", date(2024, 11, 29), 2, []time.Time{date(2024, 12, 27), date(2025, 1, 31)}},
+		{"FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO", date(2024, 11, 4), 2, []time.Time{date(2025, 1, 6), date(2025, 3, 3)}},
+		{"FREQ=YEARLY", date(2024, 2, 29), 1, []time.Time{date(2028, 2, 29)}},
+		{"FREQ=DAILY;UNTIL=20241103", date(2024, 11, 1), 10, []time.Time{date(2024, 11, 2), date(2024, 11, 3)}},
+		{"FREQ=WEEKLY;COUNT=3", date(2024, 11, 1), 10, []time.Time{date(2024, 11, 8), date(2024, 11, 15)}},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseRRule(tt.rule)
+		require.NoError(t, err, tt.rule)
+		assert.Equal(t, tt.expected, rule.Occurrences(tt.start, 1, tt.limit), tt.rule)
+	}
+}
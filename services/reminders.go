@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// Notification channels registered in main.go
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+const (
+	// maxReminderAttempts is the number of failed deliveries after which a reminder is given up on
+	maxReminderAttempts = 10
+	// reminderLease is how long a scheduler instance owns a claimed reminder; if it dies before recording
+	// the outcome, the reminder is delivered again once the lease expires
+	reminderLease = 5 * time.Minute
+	// reminderBatchSize caps the number of reminders claimed per scheduler tick
+	reminderBatchSize = 100
+)
+
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// ErrChannelNotConfigured is returned for reminders on a channel no notifier is registered for, which
+// could never fire
+var ErrChannelNotConfigured = errors.New("validation error: notification channel is not configured")
+
+type ReminderInput struct {
+	OffsetMinutes int    `validate:"min=0,max=525600" json:"offset_minutes"`
+	Channel       string `validate:"required,oneof=email webhook" json:"channel"`
+	Target        string `validate:"required,max=255" json:"target"`
+}
+
+const reminderColumns = `id, todo_id, offset_minutes, channel, target, remind_at, sent_at, failed_at, attempts, last_error`
+
+func scanReminder(row rowScanner, reminder *models.Reminder) error {
+	return row.Scan(&reminder.ID, &reminder.TodoID, &reminder.OffsetMinutes, &reminder.Channel, &reminder.Target,
+		&reminder.RemindAt, &reminder.SentAt, &reminder.FailedAt, &reminder.Attempts, &reminder.LastError)
+}
+
+// GetReminders retrieves the reminders of a todo item, earliest first
+func GetReminders(ctx context.Context, todoID string) ([]models.Reminder, error) {
+	query := `SELECT ` + reminderColumns + ` FROM todo_reminders WHERE todo_id = :1 ORDER BY remind_at, id`
+	rows, err := database.DB.QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.Reminder
+	for rows.Next() {
+		var reminder models.Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// CreateReminder validates and adds a reminder to a todo item that has a due date
+func CreateReminder(ctx context.Context, todoID string, input ReminderInput) (*models.Reminder, error) {
+	if err := validate.Struct(input); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if _, ok := getNotifier(input.Channel); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotConfigured, input.Channel)
+	}
+
+	targetRule := "email"
+	if input.Channel == ChannelWebhook {
+		targetRule = "http_url"
+	}
+	if err := validate.Var(input.Target, targetRule); err != nil {
+		return nil, fmt.Errorf("validation error: invalid %s target: %w", input.Channel, err)
+	}
+	if input.Channel == ChannelWebhook {
+		if err := CheckDestination(ctx, input.Target); err != nil {
+			return nil, err
+		}
+	}
+
+	todo, err := GetTodoByID(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if todo == nil {
+		return nil, ErrTodoNotFound
+	}
+	if !todo.DueDate.Valid {
+		return nil, errors.New("validation error: reminders require the todo to have a due date")
+	}
+
+	reminder := models.Reminder{
+		TodoID:        todo.ID,
+		OffsetMinutes: input.OffsetMinutes,
+		Channel:       input.Channel,
+		Target:        input.Target,
+		RemindAt:      todo.DueDate.Time.Add(-time.Duration(input.OffsetMinutes) * time.Minute),
+	}
+
+	query := `INSERT INTO todo_reminders (todo_id, offset_minutes, channel, target, remind_at)
+	          VALUES (:1, :2, :3, :4, :5) RETURNING id INTO :6`
+	_, err = database.DB.ExecContext(ctx, query, reminder.TodoID, reminder.OffsetMinutes, reminder.Channel, reminder.Target,
+		reminder.RemindAt, sql.Out{Dest: &reminder.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &reminder, nil
+}
+
+// DeleteReminder removes a reminder belonging to a todo item
+func DeleteReminder(ctx context.Context, todoID, reminderID string) error {
+	query := `DELETE FROM todo_reminders WHERE id = :1 AND todo_id = :2`
+	result, err := database.DB.ExecContext(ctx, query, reminderID, todoID)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// dueDateChanged reports whether a todo's due date moved to another day or was set or cleared. Due dates
+// are stored as dates, so the time of day and location are ignored: values read from the database and
+// parsed from a request differ in both even when they name the same day.
+func dueDateChanged(before, after sql.NullTime) bool {
+	if before.Valid != after.Valid {
+		return true
+	}
+	return before.Valid && before.Time.Format("2006-01-02") != after.Time.Format("2006-01-02")
+}
+
+// rescheduleReminders moves the reminders of a todo item within tx after its due date changed.
+// Reminders that were already sent or given up on fire again for the new due date.
+func rescheduleReminders(ctx context.Context, tx *sql.Tx, todoID string, due sql.NullTime) error {
+	if !due.Valid {
+		_, err := tx.ExecContext(ctx, `DELETE FROM todo_reminders WHERE todo_id = :1`, todoID)
+		return err
+	}
+
+	query := `UPDATE todo_reminders
+	          SET remind_at = :1 - NUMTODSINTERVAL(offset_minutes, 'MINUTE'),
+	              sent_at = NULL, failed_at = NULL, attempts = 0, next_attempt_at = NULL, last_error = NULL
+	          WHERE todo_id = :2`
+	_, err := tx.ExecContext(ctx, query, due.Time, todoID)
+	return err
+}
+
+// copyReminders copies the reminders of the todo item from onto the todo item to within tx,
+// scheduled relative to due. It is used when a recurring todo spawns its next occurrence.
+func copyReminders(ctx context.Context, tx *sql.Tx, from, to int, due time.Time) error {
+	query := `INSERT INTO todo_reminders (todo_id, offset_minutes, channel, target, remind_at)
+	          SELECT :1, offset_minutes, channel, target, :2 - NUMTODSINTERVAL(offset_minutes, 'MINUTE')
+	          FROM todo_reminders WHERE todo_id = :3`
+	_, err := tx.ExecContext(ctx, query, to, due, from)
+	return err
+}
+
+// StartReminderScheduler delivers due reminders every interval until ctx is cancelled.
+// Several instances may run concurrently: each reminder is claimed with a lease before delivery.
+func StartReminderScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := deliverDueReminders(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to deliver reminders", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDueReminders claims the reminders that are due and delivers each of them once
+func deliverDueReminders(ctx context.Context) error {
+	claimed, err := claimDueReminders(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range claimed {
+		todo, err := fetchTodoByIDFromDB(ctx, strconv.Itoa(reminder.TodoID))
+		if err != nil {
+			releaseReminder(ctx, reminder, err)
+			continue
+		}
+		if todo == nil {
+			// The todo was deleted after the reminder was claimed; there is nothing left to remind about
+			markReminderSent(ctx, reminder)
+			continue
+		}
+
+		notifier, ok := getNotifier(reminder.Channel)
+		if !ok {
+			releaseReminder(ctx, reminder, fmt.Errorf("notification channel %q is not configured", reminder.Channel))
+			continue
+		}
+
+		notification := Notification{
+			Key:      fmt.Sprintf("reminder-%d-%d", reminder.ID, reminder.RemindAt.Unix()),
+			Reminder: reminder,
+			Todo:     *todo,
+		}
+		if err := notifier.Notify(ctx, notification); err != nil {
+			releaseReminder(ctx, reminder, err)
+			continue
+		}
+		markReminderSent(ctx, reminder)
+	}
+
+	return nil
+}
+
+// claimDueReminders leases pending reminders whose time has come, skipping those of completed or deleted todos
+// and those currently leased by another scheduler instance
+func claimDueReminders(ctx context.Context) ([]models.Reminder, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT r.id, r.todo_id, r.offset_minutes, r.channel, r.target, r.remind_at, r.sent_at, r.failed_at, r.attempts, r.last_error
+        FROM todo_reminders r JOIN todolist t ON t.id = r.todo_id
+        WHERE r.sent_at IS NULL AND r.failed_at IS NULL AND r.remind_at <= SYSTIMESTAMP
+          AND (r.next_attempt_at IS NULL OR r.next_attempt_at <= SYSTIMESTAMP)
+          AND (r.locked_until IS NULL OR r.locked_until < SYSTIMESTAMP)
+          AND t.deleted_at IS NULL AND t.status <> 'completed'
+        FOR UPDATE OF r.locked_until SKIP LOCKED
+    `
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var reminders []models.Reminder
+	for rows.Next() && len(reminders) < reminderBatchSize {
+		var reminder models.Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	leaseUntil := time.Now().Add(reminderLease)
+	for _, reminder := range reminders {
+		if _, err := tx.ExecContext(ctx, `UPDATE todo_reminders SET locked_until = :1 WHERE id = :2`, leaseUntil, reminder.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return reminders, tx.Commit()
+}
+
+func markReminderSent(ctx context.Context, reminder models.Reminder) {
+	query := `UPDATE todo_reminders SET sent_at = SYSTIMESTAMP, locked_until = NULL, last_error = NULL WHERE id = :1`
+	if _, err := database.DB.ExecContext(ctx, query, reminder.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to mark reminder as sent", "reminder_id", reminder.ID, "error", err)
+	}
+}
+
+// releaseReminder records a failed delivery and schedules a retry with exponential backoff,
+// giving up after maxReminderAttempts
+func releaseReminder(ctx context.Context, reminder models.Reminder, cause error) {
+	attempts := reminder.Attempts + 1
+	slog.WarnContext(ctx, "Failed to deliver reminder", "reminder_id", reminder.ID, "attempt", attempts, "error", cause)
+
+	var failedAt sql.NullTime
+	if attempts >= maxReminderAttempts {
+		failedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	nextAttempt := time.Now().Add(retryBackoff(attempts))
+
+	query := `UPDATE todo_reminders SET attempts = :1, next_attempt_at = :2, failed_at = :3, last_error = :4, locked_until = NULL WHERE id = :5`
+	if _, err := database.DB.ExecContext(ctx, query, attempts, nextAttempt, failedAt, truncate(cause.Error(), 1000), reminder.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to record reminder failure", "reminder_id", reminder.ID, "error", err)
+	}
+}
+
+// retryBackoff returns the delay before retry number attempts: one minute doubling up to an hour
+func retryBackoff(attempts int) time.Duration {
+	delay := time.Minute << min(attempts-1, 6)
+	return min(delay, time.Hour)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
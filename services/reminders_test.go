@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueDateChanged(t *testing.T) {
+	stored := sql.NullTime{Time: time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local), Valid: true}
+	// The same day parsed from a request, in another location and with a time of day
+	parsed := sql.NullTime{Time: time.Date(2024, 12, 31, 9, 30, 0, 0, time.UTC), Valid: true}
+	nextDay := sql.NullTime{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	assert.False(t, dueDateChanged(stored, parsed))
+	assert.False(t, dueDateChanged(sql.NullTime{}, sql.NullTime{}))
+	assert.True(t, dueDateChanged(stored, nextDay))
+	assert.True(t, dueDateChanged(stored, sql.NullTime{}))
+	assert.True(t, dueDateChanged(sql.NullTime{}, parsed))
+}
+
+func TestCreateReminderRejectsUnavailableChannel(t *testing.T) {
+	notifiersMu.Lock()
+	previous, registered := notifiers[ChannelEmail]
+	delete(notifiers, ChannelEmail)
+	notifiersMu.Unlock()
+	t.Cleanup(func() {
+		if registered {
+			RegisterNotifier(ChannelEmail, previous)
+		}
+	})
+
+	_, err := CreateReminder(context.Background(), "1", ReminderInput{OffsetMinutes: 30, Channel: ChannelEmail, Target: "ada@example.com"})
+	assert.ErrorIs(t, err, ErrChannelNotConfigured)
+}
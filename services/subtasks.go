@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+var (
+	ErrChecklistItemNotFound = errors.New("checklist item not found")
+	ErrInvalidOrder          = errors.New("order must list every item exactly once")
+)
+
+// Progress is a completion rollup such as 3 of 5 done
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type TodoProgress struct {
+	Subtasks  Progress `json:"subtasks"`
+	Checklist Progress `json:"checklist"`
+}
+
+// CreateSubtask validates and inserts a todo item as the last child of the todo with the given parent ID
+func CreateSubtask(ctx context.Context, parentID string, todo *models.TodoList) (*models.TodoList, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	parent, err := lockTodo(ctx, tx, parentID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT NVL(MAX(position), 0) + 1 FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL`
+	if err := tx.QueryRowContext(ctx, query, parent.ID).Scan(&todo.Position); err != nil {
+		return nil, err
+	}
+	todo.ParentID = sql.NullInt64{Int64: int64(parent.ID), Valid: true}
+
+	if err := createTodoTx(ctx, tx, todo); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, parentID, strconv.Itoa(todo.ID))
+	events.publish(ctx)
+	return todo, nil
+}
+
+// GetSubtasks retrieves the children of a todo item in their display order
+func GetSubtasks(ctx context.Context, parentID string) ([]models.TodoList, error) {
+	query := `SELECT ` + todoColumns + ` FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL ORDER BY position, id`
+	rows, err := database.DB.QueryContext(ctx, query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []models.TodoList
+	for rows.Next() {
+		var todo models.TodoList
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
+// ReorderSubtasks sets the display order of the children of a todo item; ids must list every child exactly once
+func ReorderSubtasks(ctx context.Context, parentID string, ids []int) error {
+	return reorder(ctx,
+		`SELECT id FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL`,
+		`UPDATE todolist SET position = :1 WHERE id = :2`,
+		parentID, ids)
+}
+
+// GetChecklist retrieves the checklist items of a todo item in their display order
+func GetChecklist(ctx context.Context, todoID string) ([]models.ChecklistItem, error) {
+	query := `SELECT id, todo_id, content, done, position FROM todo_checklist WHERE todo_id = :1 ORDER BY position, id`
+	rows, err := database.DB.QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ChecklistItem
+	for rows.Next() {
+		var item models.ChecklistItem
+		var done int
+		if err := rows.Scan(&item.ID, &item.TodoID, &item.Content, &done, &item.Position); err != nil {
+			return nil, err
+		}
+		item.Done = done == 1
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AddChecklistItem appends an unchecked item to the checklist of a todo item
+func AddChecklistItem(ctx context.Context, todoID, content string) (*models.ChecklistItem, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > 255 {
+		return nil, errors.New("validation error: content must be between 1 and 255 characters")
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	todo, err := lockTodo(ctx, tx, todoID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	item := models.ChecklistItem{TodoID: todo.ID, Content: content}
+	query := `INSERT INTO todo_checklist (todo_id, content, position)
+	          VALUES (:1, :2, (SELECT NVL(MAX(position), 0) + 1 FROM todo_checklist WHERE todo_id = :3))
+	          RETURNING id, position INTO :4, :5`
+	_, err = tx.ExecContext(ctx, query, todo.ID, content, todo.ID, sql.Out{Dest: &item.ID}, sql.Out{Dest: &item.Position})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, tx.Commit()
+}
+
+// ToggleChecklistItem flips the done flag of a checklist item belonging to a todo item
+func ToggleChecklistItem(ctx context.Context, todoID, itemID string) (*models.ChecklistItem, error) {
+	query := `UPDATE todo_checklist SET done = 1 - done WHERE id = :1 AND todo_id = :2
+	          RETURNING id, todo_id, content, done, position INTO :3, :4, :5, :6, :7`
+
+	var item models.ChecklistItem
+	var done int
+	result, err := database.DB.ExecContext(ctx, query, itemID, todoID,
+		sql.Out{Dest: &item.ID}, sql.Out{Dest: &item.TodoID}, sql.Out{Dest: &item.Content}, sql.Out{Dest: &done}, sql.Out{Dest: &item.Position})
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrChecklistItemNotFound
+	}
+
+	item.Done = done == 1
+	return &item, nil
+}
+
+// DeleteChecklistItem removes a checklist item belonging to a todo item
+func DeleteChecklistItem(ctx context.Context, todoID, itemID string) error {
+	query := `DELETE FROM todo_checklist WHERE id = :1 AND todo_id = :2`
+	result, err := database.DB.ExecContext(ctx, query, itemID, todoID)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrChecklistItemNotFound
+	}
+	return nil
+}
+
+// ReorderChecklist sets the display order of the checklist of a todo item; ids must list every item exactly once
+func ReorderChecklist(ctx context.Context, todoID string, ids []int) error {
+	return reorder(ctx,
+		`SELECT id FROM todo_checklist WHERE todo_id = :1`,
+		`UPDATE todo_checklist SET position = :1 WHERE id = :2`,
+		todoID, ids)
+}
+
+// GetTodoProgress computes the subtask and checklist completion rollups of a todo item
+func GetTodoProgress(ctx context.Context, id string) (*TodoProgress, error) {
+	var progress TodoProgress
+
+	query := `SELECT COUNT(CASE WHEN status = 'completed' THEN 1 END), COUNT(*)
+	          FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL`
+	if err := database.DB.QueryRowContext(ctx, query, id).Scan(&progress.Subtasks.Done, &progress.Subtasks.Total); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT NVL(SUM(done), 0), COUNT(*) FROM todo_checklist WHERE todo_id = :1`
+	if err := database.DB.QueryRowContext(ctx, query, id).Scan(&progress.Checklist.Done, &progress.Checklist.Total); err != nil {
+		return nil, err
+	}
+
+	return &progress, nil
+}
+
+// completeParentIfDone completes the parent of child within tx when the parent has auto-complete enabled
+// and all of its children are now completed. Completing the parent may in turn complete its own parent.
+func completeParentIfDone(ctx context.Context, tx *sql.Tx, child *models.TodoList) error {
+	if !child.ParentID.Valid {
+		return nil
+	}
+
+	parentID := strconv.FormatInt(child.ParentID.Int64, 10)
+	parent, err := lockTodo(ctx, tx, parentID, false)
+	if errors.Is(err, ErrTodoNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !parent.AutoComplete || parent.Status == "completed" {
+		return nil
+	}
+
+	var pending int
+	query := `SELECT COUNT(*) FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL AND status <> 'completed'`
+	if err := tx.QueryRowContext(ctx, query, parent.ID).Scan(&pending); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	if _, err := completeTodoTx(ctx, tx, parentID); err != nil {
+		return err
+	}
+
+	// The caller only knows about the child, so drop the parent's cached copy here
+	invalidateTodoCache(ctx, parentID)
+	return nil
+}
+
+// reorder assigns positions 1..n to ids in a single transaction after checking that they match
+// exactly the rows returned by currentQuery for scopeID
+func reorder(ctx context.Context, currentQuery, updateQuery, scopeID string, ids []int) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, currentQuery+` FOR UPDATE`, scopeID)
+	if err != nil {
+		return err
+	}
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(current) {
+		return ErrInvalidOrder
+	}
+	for _, id := range ids {
+		if !current[id] {
+			return fmt.Errorf("%w: unknown or duplicate id %d", ErrInvalidOrder, id)
+		}
+		delete(current, id)
+	}
+
+	for i, id := range ids {
+		if _, err := tx.ExecContext(ctx, updateQuery, i+1, id); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateTodoCache(ctx, scopeID)
+	return nil
+}
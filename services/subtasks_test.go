@@ -0,0 +1,86 @@
+package services
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+// expectComplete expects completeTodoTx to complete todo, which has no recurrence
+func expectComplete(mock sqlmock.Sqlmock, todo *models.TodoList) {
+	expectLockTodo(mock, false, strconv.Itoa(todo.ID), todo)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todolist SET status = :1 WHERE id = :2")).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Updated and completed
+	expectAudit(mock, 2)
+}
+
+func TestCompletingLastSubtaskCompletesParent(t *testing.T) {
+	mock, _ := useMockDB(t)
+	parent := &models.TodoList{ID: 1, Title: "Move house", Description: "boxes", Status: "pending", AutoComplete: true, Version: 1, OwnerID: testOwner}
+	child := &models.TodoList{ID: 2, Title: "Pack books", Description: "boxes", Status: "pending", Version: 1, OwnerID: testOwner,
+		ParentID: sql.NullInt64{Int64: 1, Valid: true}}
+
+	mock.ExpectBegin()
+	expectComplete(mock, child)
+	expectLockTodo(mock, false, "1", parent)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM todolist WHERE parent_id = :1 AND deleted_at IS NULL AND status <> 'completed'")).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(0))
+	expectComplete(mock, parent)
+	mock.ExpectCommit()
+
+	todo, err := CompleteTodo(ownerContext(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", todo.Status)
+	assert.Equal(t, []string{EventTodoUpdated, EventTodoCompleted, EventTodoUpdated, EventTodoCompleted}, publishedEventTypes(t))
+}
+
+func TestCompletingSubtaskKeepsParentWithPendingSubtasks(t *testing.T) {
+	mock, _ := useMockDB(t)
+	parent := &models.TodoList{ID: 1, Title: "Move house", Description: "boxes", Status: "pending", AutoComplete: true, Version: 1, OwnerID: testOwner}
+	child := &models.TodoList{ID: 2, Title: "Pack books", Description: "boxes", Status: "pending", Version: 1, OwnerID: testOwner,
+		ParentID: sql.NullInt64{Int64: 1, Valid: true}}
+
+	mock.ExpectBegin()
+	expectComplete(mock, child)
+	expectLockTodo(mock, false, "1", parent)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM todolist WHERE parent_id = :1")).
+		WillReturnRows(sqlmock.NewRows([]string{"pending"}).AddRow(1))
+	mock.ExpectCommit()
+
+	_, err := CompleteTodo(ownerContext(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{EventTodoUpdated, EventTodoCompleted}, publishedEventTypes(t))
+}
+
+func TestCompletingSubtaskLeavesManualParent(t *testing.T) {
+	mock, _ := useMockDB(t)
+	parent := &models.TodoList{ID: 1, Title: "Move house", Description: "boxes", Status: "pending", Version: 1, OwnerID: testOwner}
+	child := &models.TodoList{ID: 2, Title: "Pack books", Description: "boxes", Status: "pending", Version: 1, OwnerID: testOwner,
+		ParentID: sql.NullInt64{Int64: 1, Valid: true}}
+
+	mock.ExpectBegin()
+	expectComplete(mock, child)
+	expectLockTodo(mock, false, "1", parent)
+	mock.ExpectCommit()
+
+	_, err := CompleteTodo(ownerContext(), "2")
+	require.NoError(t, err)
+}
+
+func TestCreateTodoRejectsMissingParent(t *testing.T) {
+	mock, _ := useMockDB(t)
+
+	mock.ExpectBegin()
+	expectLockTodo(mock, false, "99", nil)
+	mock.ExpectRollback()
+
+	todo := &models.TodoList{Title: "Pack books", Description: "boxes", Status: "pending", ParentID: sql.NullInt64{Int64: 99, Valid: true}}
+	_, err := CreateTodo(ownerContext(), todo)
+	assert.ErrorIs(t, err, ErrInvalidParent)
+}
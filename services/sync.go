@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"todolist/database"
+	"todolist/models"
+)
+
+// Sync change operations accepted by PushChanges
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+const (
+	// DefaultSyncLimit is the number of changes returned per page when the client does not ask for a limit
+	DefaultSyncLimit = 100
+	// MaxSyncLimit caps the number of changes returned per page
+	MaxSyncLimit = 1000
+	// MaxSyncChanges caps the number of changes accepted in a single push
+	MaxSyncChanges = 500
+)
+
+var (
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	ErrInvalidSync      = errors.New("invalid sync request")
+	ErrSyncConflict     = errors.New("todo was changed on the server since the client last synced it")
+)
+
+// SyncChanges is a page of the changes made to todos since a sync token. Todos that were moved to the trash
+// or purged since are listed in Deleted; clients pass SyncToken back to get the next page or later changes.
+type SyncChanges struct {
+	Todos     []models.TodoList `json:"todos"`
+	Deleted   []int             `json:"deleted"`
+	SyncToken string            `json:"sync_token"`
+	HasMore   bool              `json:"has_more"`
+}
+
+// SyncChange is a change made by a client while offline. Updates and deletes carry the version of the todo
+// the client last saw so concurrent changes on the server are detected instead of overwritten.
+type SyncChange struct {
+	Op          string           `json:"op"`
+	ClientID    string           `json:"client_id"`
+	ID          string           `json:"id"`
+	BaseVersion int              `json:"base_version"`
+	Todo        *models.TodoList `json:"todo"`
+}
+
+type SyncPushRequest struct {
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncResult is the outcome of a single pushed change. On conflict, ServerTodo holds the current server copy
+// for the client to merge and push again with its version.
+type SyncResult struct {
+	Index      int              `json:"index"`
+	Op         string           `json:"op"`
+	ClientID   string           `json:"client_id,omitempty"`
+	ID         string           `json:"id,omitempty"`
+	Status     int              `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	Todo       *models.TodoList `json:"todo,omitempty"`
+	ServerTodo *models.TodoList `json:"server_todo,omitempty"`
+}
+
+// ParseSyncToken parses a token returned in SyncChanges; an empty token starts from the beginning
+func ParseSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
+
+// GetChanges retrieves up to limit changes made to the todos of userID after the sync token since, oldest
+// first. Only the latest state of each todo is returned, so a todo changed several times appears once.
+// Changes are numbered in the order they committed, so a token never moves past a change that may still
+// appear, however long the transaction making it ran.
+func GetChanges(ctx context.Context, userID uint, since string, limit int) (*SyncChanges, error) {
+	seq, err := ParseSyncToken(since)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > MaxSyncLimit {
+		limit = DefaultSyncLimit
+	}
+	if err := numberSyncChanges(ctx); err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		todoID int
+		seq    int64
+	}
+
+	// Read one change more than needed to know whether another page follows
+	query := `SELECT todo_id, MAX(sync_seq) FROM todo_changes WHERE owner_id = :1 AND sync_seq > :2
+	          GROUP BY todo_id ORDER BY MAX(sync_seq) FETCH FIRST :3 ROWS ONLY`
+	rows, err := database.DB.QueryContext(ctx, query, userID, seq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.todoID, &c.seq); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &SyncChanges{Todos: []models.TodoList{}, Deleted: []int{}, SyncToken: strconv.FormatInt(seq, 10)}
+	if result.HasMore = len(changes) > limit; result.HasMore {
+		changes = changes[:limit]
+	}
+	if len(changes) == 0 {
+		return result, nil
+	}
+
+	ids := make([]int, len(changes))
+	for i, c := range changes {
+		ids[i] = c.todoID
+	}
+	todos, err := GetTodosByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Todos that are no longer found were moved to the trash or purged since
+	for _, c := range changes {
+		if todo, ok := todos[c.todoID]; ok {
+			result.Todos = append(result.Todos, *todo)
+		} else {
+			result.Deleted = append(result.Deleted, c.todoID)
+		}
+	}
+	result.SyncToken = strconv.FormatInt(changes[len(changes)-1].seq, 10)
+
+	return result, nil
+}
+
+// numberSyncChanges gives the committed changes that have no sequence number yet the next numbers, and
+// drops the changes they supersede. A change is only seen once its transaction has committed, and runs are
+// serialized on the row of todo_sync_lock, each committing before the next one starts. So a change
+// committing after a token was handed out gets a number above it, in a later run.
+func numberSyncChanges(ctx context.Context) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lock int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM todo_sync_lock WHERE id = 1 FOR UPDATE`).Scan(&lock); err != nil {
+		return err
+	}
+	var floor int64
+	if err := tx.QueryRowContext(ctx, `SELECT TODOLIST_SYNC_SEQ.NEXTVAL FROM DUAL`).Scan(&floor); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE todo_changes SET sync_seq = TODOLIST_SYNC_SEQ.NEXTVAL WHERE sync_seq IS NULL`)
+	if err != nil {
+		return err
+	}
+	if numbered, err := result.RowsAffected(); err != nil || numbered == 0 {
+		return err
+	}
+
+	// Only the latest change of a todo is read, so the earlier ones of the todos just changed can go
+	_, err = tx.ExecContext(ctx, `DELETE FROM todo_changes c
+	     WHERE c.todo_id IN (SELECT todo_id FROM todo_changes WHERE sync_seq > :1)
+	       AND EXISTS (SELECT 1 FROM todo_changes d WHERE d.todo_id = c.todo_id AND d.sync_seq > c.sync_seq)`, floor)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PushChanges applies the changes a client made while offline inside a single database transaction.
+// Each change runs under its own savepoint, so a conflicting or invalid change is reported without
+// undoing the others. Deleting a todo that is already gone succeeds, so retried pushes are harmless.
+func PushChanges(ctx context.Context, req SyncPushRequest) ([]SyncResult, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	if len(req.Changes) == 0 || len(req.Changes) > MaxSyncChanges {
+		return nil, fmt.Errorf("%w: between 1 and %d changes are required", ErrInvalidSync, MaxSyncChanges)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]SyncResult, len(req.Changes))
+	var touched []string
+
+	for i, change := range req.Changes {
+		results[i] = SyncResult{Index: i, Op: change.Op, ClientID: change.ClientID, ID: change.ID}
+
+		mark := events.mark()
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT sync_change`); err != nil {
+			return nil, err
+		}
+
+		todo, err := applySyncChange(ctx, tx, change)
+		if err != nil {
+			results[i].Status = syncErrorStatus(err)
+			results[i].Error = err.Error()
+			var conflict *syncConflictError
+			if errors.As(err, &conflict) {
+				results[i].ServerTodo = conflict.current
+			}
+
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT sync_change`); err != nil {
+				return nil, err
+			}
+			events.truncate(mark)
+			continue
+		}
+
+		results[i].Status = fiber.StatusOK
+		if change.Op == SyncCreate {
+			results[i].Status = fiber.StatusCreated
+		}
+		if todo != nil {
+			results[i].ID = strconv.Itoa(todo.ID)
+			results[i].Todo = todo
+		}
+		touched = append(touched, results[i].ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, touched...)
+	events.publish(ctx)
+	return results, nil
+}
+
+// syncConflictError carries the server copy of a todo that was changed concurrently
+type syncConflictError struct {
+	current *models.TodoList
+}
+
+func (e *syncConflictError) Error() string {
+	return fmt.Sprintf("%s: server version is %d", ErrSyncConflict, e.current.Version)
+}
+
+func (e *syncConflictError) Unwrap() error {
+	return ErrSyncConflict
+}
+
+func applySyncChange(ctx context.Context, tx *sql.Tx, change SyncChange) (*models.TodoList, error) {
+	switch change.Op {
+	case SyncCreate:
+		if change.Todo == nil {
+			return nil, fmt.Errorf("%w: todo is required", ErrInvalidSync)
+		}
+		return change.Todo, createTodoTx(ctx, tx, change.Todo)
+	case SyncUpdate:
+		if change.Todo == nil || change.ID == "" {
+			return nil, fmt.Errorf("%w: id and todo are required", ErrInvalidSync)
+		}
+		if err := checkSyncVersion(ctx, tx, change); err != nil {
+			return nil, err
+		}
+		return change.Todo, updateTodoTx(ctx, tx, change.ID, change.Todo)
+	case SyncDelete:
+		if change.ID == "" {
+			return nil, fmt.Errorf("%w: id is required", ErrInvalidSync)
+		}
+		err := checkSyncVersion(ctx, tx, change)
+		if errors.Is(err, ErrTodoNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, deleteTodoTx(ctx, tx, change.ID)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidSync, change.Op)
+	}
+}
+
+// checkSyncVersion locks the todo targeted by change and fails with a syncConflictError
+// when it was changed since the version the client based its change on
+func checkSyncVersion(ctx context.Context, tx *sql.Tx, change SyncChange) error {
+	current, err := lockTodo(ctx, tx, change.ID, false)
+	if err != nil {
+		return err
+	}
+	if current.Version != change.BaseVersion {
+		return &syncConflictError{current: current}
+	}
+	return nil
+}
+
+// syncErrorStatus maps the error of a single pushed change to an HTTP status code
+func syncErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrSyncConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrInvalidSync):
+		return fiber.StatusBadRequest
+	default:
+		return bulkErrorStatus(err)
+	}
+}
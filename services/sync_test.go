@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func TestParseSyncToken(t *testing.T) {
+	seq, err := ParseSyncToken("")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), seq)
+
+	seq, err = ParseSyncToken("42")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), seq)
+
+	for _, token := range []string{"abc", "-1", "1.5"} {
+		_, err := ParseSyncToken(token)
+		assert.ErrorIs(t, err, ErrInvalidSyncToken, token)
+	}
+}
+
+func TestSyncErrorStatus(t *testing.T) {
+	conflict := &syncConflictError{current: &models.TodoList{ID: 1, Version: 3}}
+	assert.Equal(t, fiber.StatusConflict, syncErrorStatus(conflict))
+	assert.Equal(t, fiber.StatusBadRequest, syncErrorStatus(fmt.Errorf("%w: id is required", ErrInvalidSync)))
+	assert.Equal(t, fiber.StatusNotFound, syncErrorStatus(ErrTodoNotFound))
+}
+
+// expectNumberSyncChanges expects numberSyncChanges to number the committed changes, numbered of them
+func expectNumberSyncChanges(mock sqlmock.Sqlmock, numbered int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo_sync_lock WHERE id = 1 FOR UPDATE")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT TODOLIST_SYNC_SEQ.NEXTVAL FROM DUAL")).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(40))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_changes SET sync_seq = TODOLIST_SYNC_SEQ.NEXTVAL WHERE sync_seq IS NULL")).
+		WillReturnResult(sqlmock.NewResult(0, numbered))
+	if numbered == 0 {
+		mock.ExpectRollback()
+		return
+	}
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo_changes c")).WithArgs(int64(40)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestGetChangesNumbersCommittedChangesFirst(t *testing.T) {
+	mock, _ := useMockDB(t)
+	expectNumberSyncChanges(mock, 3)
+	// Only the changes of the owner are read, and todos no longer found were deleted
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todo_changes WHERE owner_id = :1 AND sync_seq > :2")).
+		WithArgs(uint(testOwner.Int64), int64(40), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "sync_seq"}).AddRow(5, 41).AddRow(7, 42).AddRow(9, 43))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todolist WHERE deleted_at IS NULL AND id IN (:1, :2)")).
+		WithArgs(5, 7).
+		WillReturnRows(todoRows(models.TodoList{ID: 7, Title: "Buy milk", Description: "groceries", Status: "pending", OwnerID: testOwner}))
+
+	changes, err := GetChanges(context.Background(), uint(testOwner.Int64), "40", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{5}, changes.Deleted)
+	require.Len(t, changes.Todos, 1)
+	assert.Equal(t, 7, changes.Todos[0].ID)
+	assert.Equal(t, "42", changes.SyncToken)
+	assert.True(t, changes.HasMore)
+
+	// Without new changes the token stays where it was
+	expectNumberSyncChanges(mock, 0)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todo_changes WHERE owner_id = :1")).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "sync_seq"}))
+	changes, err = GetChanges(context.Background(), uint(testOwner.Int64), "43", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "43", changes.SyncToken)
+	assert.False(t, changes.HasMore)
+	assert.Empty(t, changes.Todos)
+}
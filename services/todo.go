@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/redis/go-redis/v9"
 	"todolist/database"
+	"todolist/metrics"
 	"todolist/models"
 )
 
@@ -33,6 +35,41 @@ type TodoInput struct {
 	Description string       `validate:"required" json:"description"`
 	Status      string       `validate:"required,oneof=pending completed" json:"status"`
 	DueDate     sql.NullTime `validate:"required" json:"due_date"`
+	Priority    string       `validate:"omitempty,len=1,alpha,uppercase" json:"priority"`
+}
+
+var (
+	ErrTodoNotFound = errors.New("todo not found")
+	// ErrInvalidParent is returned when a todo is created under a parent that does not exist or is in the trash
+	ErrInvalidParent = errors.New("invalid parent todo")
+)
+
+// todoColumns lists the todolist columns read by scanTodo, in order
+const todoColumns = `id, title, description, status, due_date, deleted_at, parent_id, position, auto_complete, recurrence, occurrence, version, priority, owner_id`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo reads a row selected with todoColumns into todo
+func scanTodo(row rowScanner, todo *models.TodoList) error {
+	var autoComplete int
+	var recurrence, priority sql.NullString
+	err := row.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.DueDate, &todo.DeletedAt,
+		&todo.ParentID, &todo.Position, &autoComplete, &recurrence, &todo.Occurrence, &todo.Version, &priority, &todo.OwnerID)
+	todo.AutoComplete = autoComplete == 1
+	todo.Recurrence = recurrence.String
+	todo.Priority = priority.String
+	return err
+}
+
+// boolToNumber converts a bool to the 0/1 representation stored in NUMBER(1) columns
+func boolToNumber(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 var (
@@ -48,7 +85,8 @@ func GetAllTodos(ctx context.Context, page, limit int) (*PaginatedTodos, error)
 
 	if errors.Is(err, redis.Nil) {
 		// Cache miss, fetch from database
-		todos, pagination, err := fetchPaginatedTodosFromDB(page, limit)
+		metrics.CacheLookup(metrics.CacheTodos, false)
+		todos, pagination, err := fetchPaginatedTodosFromDB(ctx, page, limit)
 		if err != nil {
 			return nil, err
 		}
@@ -56,7 +94,7 @@ func GetAllTodos(ctx context.Context, page, limit int) (*PaginatedTodos, error)
 		// Cache the retrieved todos
 		err = cacheTodos(ctx, cacheKey, todos, pagination)
 		if err != nil {
-			log.Println("Failed to cache todos:", err)
+			slog.WarnContext(ctx, "Failed to cache todos", "error", err)
 		}
 
 		return &PaginatedTodos{Todos: todos, CurrentPage: pagination.CurrentPage, TotalPages: pagination.TotalPages, TotalTasks: pagination.TotalTasks}, nil
@@ -65,6 +103,7 @@ func GetAllTodos(ctx context.Context, page, limit int) (*PaginatedTodos, error)
 	}
 
 	// Unmarshal cached data
+	metrics.CacheLookup(metrics.CacheTodos, true)
 	var paginatedTodos PaginatedTodos
 	err = json.Unmarshal([]byte(cacheData), &paginatedTodos)
 	if err != nil {
@@ -75,15 +114,16 @@ func GetAllTodos(ctx context.Context, page, limit int) (*PaginatedTodos, error)
 }
 
 // fetchPaginatedTodosFromDB retrieves todos from the database based on pagination
-func fetchPaginatedTodosFromDB(page, limit int) ([]models.TodoList, PaginationInfo, error) {
+func fetchPaginatedTodosFromDB(ctx context.Context, page, limit int) ([]models.TodoList, PaginationInfo, error) {
 	startRow := (page - 1) * limit
 	query := `
-        SELECT id, title, description, status, due_date
+        SELECT ` + todoColumns + `
         FROM (
-            SELECT id, title, description, status, due_date, ROW_NUMBER() OVER (ORDER BY id) AS rn FROM todolist
+            SELECT ` + todoColumns + `, ROW_NUMBER() OVER (ORDER BY id) AS rn
+            FROM todolist WHERE deleted_at IS NULL
         ) WHERE rn BETWEEN :1 AND :2
     `
-	rows, err := database.DB.Query(query, startRow+1, startRow+limit)
+	rows, err := database.DB.QueryContext(ctx, query, startRow+1, startRow+limit)
 	if err != nil {
 		return nil, PaginationInfo{}, err
 	}
@@ -93,7 +133,7 @@ func fetchPaginatedTodosFromDB(page, limit int) ([]models.TodoList, PaginationIn
 	var todos []models.TodoList
 	for rows.Next() {
 		var todo models.TodoList
-		if err := rows.Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.DueDate); err != nil {
+		if err := scanTodo(rows, &todo); err != nil {
 			return nil, PaginationInfo{}, err
 		}
 		todos = append(todos, todo)
@@ -101,8 +141,8 @@ func fetchPaginatedTodosFromDB(page, limit int) ([]models.TodoList, PaginationIn
 
 	// Calculate pagination info
 	var totalTasks int
-	countQuery := `SELECT COUNT(*) FROM todolist`
-	if err := database.DB.QueryRow(countQuery).Scan(&totalTasks); err != nil {
+	countQuery := `SELECT COUNT(*) FROM todolist WHERE deleted_at IS NULL`
+	if err := database.DB.QueryRowContext(ctx, countQuery).Scan(&totalTasks); err != nil {
 		return nil, PaginationInfo{}, err
 	}
 
@@ -137,7 +177,8 @@ func GetTodoByID(ctx context.Context, id string) (*models.TodoList, error) {
 	cacheData, err := database.RedisClient.Get(ctx, cacheKey).Result()
 	if err == redis.Nil {
 		// Cache miss, query database
-		todo, err := fetchTodoByIDFromDB(id)
+		metrics.CacheLookup(metrics.CacheTodo, false)
+		todo, err := fetchTodoByIDFromDB(ctx, id)
 		if err != nil {
 			return nil, err
 		}
@@ -153,15 +194,16 @@ func GetTodoByID(ctx context.Context, id string) (*models.TodoList, error) {
 	}
 
 	// Cache hit
+	metrics.CacheLookup(metrics.CacheTodo, true)
 	var todo models.TodoList
 	err = json.Unmarshal([]byte(cacheData), &todo)
 	return &todo, err
 }
 
-func fetchTodoByIDFromDB(id string) (*models.TodoList, error) {
-	query := `SELECT id, title, description, status, due_date FROM todolist WHERE id = :1`
+func fetchTodoByIDFromDB(ctx context.Context, id string) (*models.TodoList, error) {
+	query := `SELECT ` + todoColumns + ` FROM todolist WHERE id = :1 AND deleted_at IS NULL`
 	var todo models.TodoList
-	err := database.DB.QueryRow(query, id).Scan(&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.DueDate)
+	err := scanTodo(database.DB.QueryRowContext(ctx, query, id), &todo)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -171,16 +213,66 @@ func fetchTodoByIDFromDB(id string) (*models.TodoList, error) {
 	return &todo, nil
 }
 
-func CreateTodo(todo *models.TodoList) (*models.TodoList, error) {
+// CreateTodo validates and inserts a todo item, recording the creation in the audit log
+func CreateTodo(ctx context.Context, todo *models.TodoList) (*models.TodoList, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := createTodoTx(ctx, tx, todo); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, strconv.Itoa(todo.ID))
+	events.publish(ctx)
+	return todo, nil
+}
+
+// createTodoTx inserts todo within tx on behalf of the actor stored in ctx, who becomes its owner
+func createTodoTx(ctx context.Context, tx *sql.Tx, todo *models.TodoList) error {
+	// Whatever the input says, todos belong to the user creating them
+	todo.OwnerID = sql.NullInt64{}
+	if userID, ok := ActorFromContext(ctx); ok {
+		todo.OwnerID = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	return insertTodoTx(ctx, tx, todo)
+}
+
+// insertTodoTx validates and inserts todo within tx, keeping its owner
+func insertTodoTx(ctx context.Context, tx *sql.Tx, todo *models.TodoList) error {
 	// Validate the input struct
 	input := TodoInput{
 		Title:       todo.Title,
 		Description: todo.Description,
 		Status:      todo.Status,
 		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
 	}
 	if err := validate.Struct(input); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return fmt.Errorf("validation error: %w", err)
+	}
+	if err := validateRecurrence(todo); err != nil {
+		return err
+	}
+	if todo.Occurrence < 1 {
+		todo.Occurrence = 1
+	}
+	if todo.ParentID.Valid {
+		// The parent may come from any client input, so it gets the same checks as CreateSubtask
+		_, err := lockTodo(ctx, tx, strconv.FormatInt(todo.ParentID.Int64, 10), false)
+		if errors.Is(err, ErrTodoNotFound) {
+			return fmt.Errorf("%w: todo %d does not exist or is in the trash", ErrInvalidParent, todo.ParentID.Int64)
+		} else if err != nil {
+			return err
+		}
 	}
 
 	var query string
@@ -188,45 +280,231 @@ func CreateTodo(todo *models.TodoList) (*models.TodoList, error) {
 
 	if todo.DueDate.Valid {
 		// If due date is provided, include it in the query
-		query = `INSERT INTO todolist (title, description, status, due_date) 
-		         VALUES (:1, :2, :3, TO_DATE(:4, 'YYYY-MM-DD')) RETURNING id INTO :5`
-		args = []interface{}{todo.Title, todo.Description, todo.Status, todo.DueDate.Time.Format("2006-01-02"), sql.Out{Dest: &todo.ID}}
+		query = `INSERT INTO todolist (title, description, status, auto_complete, parent_id, position, recurrence, occurrence, priority, owner_id, due_date) 
+		         VALUES (:1, :2, :3, :4, :5, :6, :7, :8, :9, :10, TO_DATE(:11, 'YYYY-MM-DD')) RETURNING id, version INTO :12, :13`
+		args = []interface{}{todo.Title, todo.Description, todo.Status, boolToNumber(todo.AutoComplete), todo.ParentID, todo.Position,
+			todo.Recurrence, todo.Occurrence, todo.Priority, todo.OwnerID, todo.DueDate.Time.Format("2006-01-02"), sql.Out{Dest: &todo.ID}, sql.Out{Dest: &todo.Version}}
 	} else {
 		// If due date is not provided, omit it from the query
-		query = `INSERT INTO todolist (title, description, status) 
-		         VALUES (:1, :2, :3) RETURNING id INTO :4`
-		args = []interface{}{todo.Title, todo.Description, todo.Status, sql.Out{Dest: &todo.ID}}
+		query = `INSERT INTO todolist (title, description, status, auto_complete, parent_id, position, recurrence, occurrence, priority, owner_id) 
+		         VALUES (:1, :2, :3, :4, :5, :6, :7, :8, :9, :10) RETURNING id, version INTO :11, :12`
+		args = []interface{}{todo.Title, todo.Description, todo.Status, boolToNumber(todo.AutoComplete), todo.ParentID, todo.Position,
+			todo.Recurrence, todo.Occurrence, todo.Priority, todo.OwnerID, sql.Out{Dest: &todo.ID}, sql.Out{Dest: &todo.Version}}
 	}
 
 	// Execute the query
-	_, err := database.DB.Exec(query, args...)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, strconv.Itoa(todo.ID), AuditCreate, nil, todo)
+}
+
+// UpdateTodoByID validates and updates a todo item by ID, recording the changed fields in the audit log
+func UpdateTodoByID(ctx context.Context, id string, todo *models.TodoList) (*models.TodoList, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	if err := updateTodoTx(ctx, tx, id, todo); err != nil {
+		return nil, err
+	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, id)
+	events.publish(ctx)
 	return todo, nil
 }
 
-// UpdateTodoByID validates and updates a todo item by ID
-func UpdateTodoByID(ctx context.Context, id string, todo *models.TodoList) (*models.TodoList, error) {
+func updateTodoTx(ctx context.Context, tx *sql.Tx, id string, todo *models.TodoList) error {
 	input := TodoInput{
 		Title:       todo.Title,
 		Description: todo.Description,
 		Status:      todo.Status,
 		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
 	}
 	if err := validate.Struct(input); err != nil {
-		return nil, fmt.Errorf("validation failed: %w", err)
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if err := validateRecurrence(todo); err != nil {
+		return err
+	}
+
+	before, err := lockTodo(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+	todo.ID = before.ID
+	todo.ParentID = before.ParentID
+	todo.Position = before.Position
+	todo.Occurrence = before.Occurrence
+	todo.OwnerID = before.OwnerID
+
+	query := `UPDATE todolist SET title = :1, description = :2, status = :3, due_date = :4, auto_complete = :5, recurrence = :6,
+	          priority = :7 WHERE id = :8 RETURNING version INTO :9`
+	_, err = tx.ExecContext(ctx, query, todo.Title, todo.Description, todo.Status, todo.DueDate, boolToNumber(todo.AutoComplete),
+		todo.Recurrence, todo.Priority, id, sql.Out{Dest: &todo.Version})
+	if err != nil {
+		return err
 	}
 
-	query := `UPDATE todolist SET title = :1, description = :2, status = :3, due_date = :4 WHERE id = :5`
-	_, err := database.DB.Exec(query, todo.Title, todo.Description, todo.Status, todo.DueDate, id)
-	return todo, err
+	if err := recordAudit(ctx, tx, id, AuditUpdate, before, todo); err != nil {
+		return err
+	}
+
+	if dueDateChanged(before.DueDate, todo.DueDate) {
+		if err := rescheduleReminders(ctx, tx, id, todo.DueDate); err != nil {
+			return err
+		}
+	}
+
+	if before.Status != "completed" && todo.Status == "completed" {
+		return onTodoCompleted(ctx, tx, todo)
+	}
+	return nil
 }
 
-// DeleteTodoByID deletes a todo item by ID
+// DeleteTodoByID moves a todo item to the trash by ID, recording the deletion in the audit log
 func DeleteTodoByID(ctx context.Context, id string) error {
-	query := `DELETE FROM todolist WHERE id = :1`
-	_, err := database.DB.Exec(query, id)
-	return err
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTodoTx(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateTodoCache(ctx, id)
+	events.publish(ctx)
+	return nil
+}
+
+func deleteTodoTx(ctx context.Context, tx *sql.Tx, id string) error {
+	before, err := lockTodo(ctx, tx, id, false)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE todolist SET deleted_at = SYSTIMESTAMP WHERE id = :1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, id, AuditDelete, before, nil)
+}
+
+// CompleteTodo marks a todo item as completed by ID, applying the completion rules such as recurrence
+func CompleteTodo(ctx context.Context, id string) (*models.TodoList, error) {
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	todo, err := completeTodoTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, id)
+	events.publish(ctx)
+	return todo, nil
+}
+
+// completeTodoTx marks a todo item as completed by ID within tx
+func completeTodoTx(ctx context.Context, tx *sql.Tx, id string) (*models.TodoList, error) {
+	before, err := lockTodo(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *before
+	after.Status = "completed"
+
+	query := `UPDATE todolist SET status = :1 WHERE id = :2 RETURNING version INTO :3`
+	if _, err := tx.ExecContext(ctx, query, after.Status, id, sql.Out{Dest: &after.Version}); err != nil {
+		return nil, err
+	}
+
+	if err := recordAudit(ctx, tx, id, AuditUpdate, before, &after); err != nil {
+		return nil, err
+	}
+
+	if before.Status != "completed" {
+		if err := onTodoCompleted(ctx, tx, &after); err != nil {
+			return nil, err
+		}
+	}
+	return &after, nil
+}
+
+// onTodoCompleted applies the rules triggered within tx when a todo item becomes completed
+func onTodoCompleted(ctx context.Context, tx *sql.Tx, todo *models.TodoList) error {
+	if err := createNextOccurrence(ctx, tx, todo); err != nil {
+		return err
+	}
+	return completeParentIfDone(ctx, tx, todo)
+}
+
+// lockTodo reads a todo item by ID and locks its row until tx ends.
+// trashed selects whether the todo must be in the trash or not; ErrTodoNotFound is returned otherwise.
+func lockTodo(ctx context.Context, tx *sql.Tx, id string, trashed bool) (*models.TodoList, error) {
+	query := `SELECT ` + todoColumns + ` FROM todolist WHERE id = :1 AND deleted_at IS NULL FOR UPDATE`
+	if trashed {
+		query = `SELECT ` + todoColumns + ` FROM todolist WHERE id = :1 AND deleted_at IS NOT NULL FOR UPDATE`
+	}
+
+	var todo models.TodoList
+	err := scanTodo(tx.QueryRowContext(ctx, query, id), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTodoNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// invalidateTodoCache removes the cached todos with the given IDs and every cached page of todos
+func invalidateTodoCache(ctx context.Context, ids ...string) {
+	var keys []string
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf(todoByIDCache, id))
+	}
+
+	iter := database.RedisClient.Scan(ctx, 0, "todos:page:*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to scan cached todo pages", "error", err)
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+	if err := database.RedisClient.Del(ctx, keys...).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate todo cache", "error", err)
+	}
 }
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"todolist/models"
+)
+
+// Keys of the key:value tags written after the text of a todo.txt line or Markdown checklist item.
+// The todo.txt convention is to keep a completed task's priority in a pri: tag.
+const (
+	todoTxtDue         = "due"
+	todoTxtRRule       = "rrule"
+	todoTxtRec         = "rec"
+	todoTxtPriority    = "pri"
+	todoTxtDescription = "desc"
+	todoTxtAuto        = "auto"
+)
+
+var (
+	todoTxtPriorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtDatePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtRecPattern      = regexp.MustCompile(`^\+?(\d*)([dwmy])$`)
+	markdownItemPattern    = regexp.MustCompile(`^\s*[-*+] \[([ xX])\] (.*)$`)
+)
+
+// formatTodoTxtLine renders a todo as a todo.txt line such as "(A) Call mom +family due:2024-05-01".
+// Contexts and projects are part of the title, so they round-trip as they are.
+func formatTodoTxtLine(todo *models.TodoList) string {
+	var parts []string
+	if todo.Status == "completed" {
+		parts = append(parts, "x")
+	} else if todo.Priority != "" {
+		parts = append(parts, "("+todo.Priority+")")
+	}
+	parts = append(parts, todo.Title)
+	if todo.Status == "completed" && todo.Priority != "" {
+		parts = append(parts, todoTxtPriority+":"+todo.Priority)
+	}
+	return strings.Join(append(parts, todoTxtTags(todo, true)...), " ")
+}
+
+// formatMarkdownItem renders a todo as a Markdown checklist item, with its description on indented lines below
+func formatMarkdownItem(todo *models.TodoList) string {
+	check := " "
+	if todo.Status == "completed" {
+		check = "x"
+	}
+
+	parts := []string{"- [" + check + "]"}
+	if todo.Priority != "" {
+		parts = append(parts, "("+todo.Priority+")")
+	}
+	parts = append(parts, todo.Title)
+	item := strings.Join(append(parts, todoTxtTags(todo, false)...), " ") + "\n"
+
+	if todo.Description != "" && todo.Description != todo.Title {
+		for _, line := range strings.Split(todo.Description, "\n") {
+			item += "  " + line + "\n"
+		}
+	}
+	return item
+}
+
+// todoTxtTags returns the key:value tags of a todo. A todo.txt line cannot hold free text other than the
+// title, so withDescription encodes a description that differs from the title into a desc: tag.
+func todoTxtTags(todo *models.TodoList, withDescription bool) []string {
+	var tags []string
+	if todo.DueDate.Valid {
+		tags = append(tags, todoTxtDue+":"+todo.DueDate.Time.Format("2006-01-02"))
+	}
+	if todo.Recurrence != "" {
+		tags = append(tags, todoTxtRRule+":"+todo.Recurrence)
+	}
+	if todo.AutoComplete {
+		tags = append(tags, todoTxtAuto+":true")
+	}
+	if withDescription && todo.Description != "" && todo.Description != todo.Title {
+		tags = append(tags, todoTxtDescription+":"+url.PathEscape(todo.Description))
+	}
+	return tags
+}
+
+// readTodoTxtRecords reads one import record per non-empty line of a todo.txt file
+func readTodoTxtRecords(data []byte) ([]map[string]string, error) {
+	var records []map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+
+		record := map[string]string{"status": "pending"}
+		if tokens[0] == "x" {
+			record["status"] = "completed"
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 {
+			if match := todoTxtPriorityPattern.FindStringSubmatch(tokens[0]); match != nil {
+				record["priority"] = match[1]
+				tokens = tokens[1:]
+			}
+		}
+		// Completion and creation dates are not tracked, so they are skipped
+		for i := 0; i < 2 && len(tokens) > 0 && todoTxtDatePattern.MatchString(tokens[0]); i++ {
+			tokens = tokens[1:]
+		}
+
+		if err := parseTodoTxtText(tokens, record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+		if len(records) > MaxImportRows {
+			break
+		}
+	}
+	return records, scanner.Err()
+}
+
+// readMarkdownRecords reads one import record per checklist item ("- [ ] ..." or "- [x] ...") of a Markdown
+// document. Indented lines following an item form its description; any other content is ignored.
+func readMarkdownRecords(data []byte) ([]map[string]string, error) {
+	var records []map[string]string
+	var record map[string]string
+	var description []string
+
+	flush := func() {
+		if record != nil && len(description) > 0 {
+			record["description"] = strings.Join(description, "\n")
+		}
+		record, description = nil, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		if match := markdownItemPattern.FindStringSubmatch(text); match != nil {
+			flush()
+			record = map[string]string{"status": "pending"}
+			if match[1] != " " {
+				record["status"] = "completed"
+			}
+
+			tokens := strings.Fields(match[2])
+			if len(tokens) > 0 {
+				if priority := todoTxtPriorityPattern.FindStringSubmatch(tokens[0]); priority != nil {
+					record["priority"] = priority[1]
+					tokens = tokens[1:]
+				}
+			}
+			if err := parseTodoTxtText(tokens, record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			records = append(records, record)
+			if len(records) > MaxImportRows {
+				break
+			}
+			continue
+		}
+
+		if record != nil && (strings.HasPrefix(text, "  ") || strings.HasPrefix(text, "\t")) {
+			description = append(description, strings.TrimSpace(text))
+			continue
+		}
+		flush()
+	}
+	flush()
+	return records, scanner.Err()
+}
+
+// parseTodoTxtText fills record from the text of a task: known key:value tags set their field and every
+// other word, including +project and @context tags, is kept in the title
+func parseTodoTxtText(tokens []string, record map[string]string) error {
+	var title []string
+	for _, token := range tokens {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" {
+			title = append(title, token)
+			continue
+		}
+
+		switch key {
+		case todoTxtDue:
+			record["due_date"] = value
+		case todoTxtRRule:
+			record["recurrence"] = value
+		case todoTxtRec:
+			rule, err := parseTodoTxtRec(value)
+			if err != nil {
+				return err
+			}
+			record["recurrence"] = rule
+		case todoTxtPriority:
+			record["priority"] = value
+		case todoTxtAuto:
+			record["auto_complete"] = value
+		case todoTxtDescription:
+			description, err := url.PathUnescape(value)
+			if err != nil {
+				return fmt.Errorf("invalid desc %q", value)
+			}
+			record["description"] = description
+		default:
+			title = append(title, token)
+		}
+	}
+
+	record["title"] = strings.Join(title, " ")
+	if record["description"] == "" {
+		record["description"] = record["title"]
+	}
+	return nil
+}
+
+// parseTodoTxtRec converts the rec: tag used by todo.txt clients, such as rec:1w or rec:+2d, into a recurrence rule.
+// Business-day recurrence (rec:5b) has no equivalent and is rejected.
+func parseTodoTxtRec(value string) (string, error) {
+	match := todoTxtRecPattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("unsupported rec %q", value)
+	}
+
+	interval := 1
+	if match[1] != "" {
+		interval, _ = strconv.Atoi(match[1])
+		if interval < 1 {
+			return "", fmt.Errorf("unsupported rec %q", value)
+		}
+	}
+
+	rule := RRule{Interval: interval}
+	switch match[2] {
+	case "d":
+		rule.Freq = FreqDaily
+	case "w":
+		rule.Freq = FreqWeekly
+	case "m":
+		rule.Freq = FreqMonthly
+	case "y":
+		rule.Freq = FreqYearly
+	}
+	return rule.String(), nil
+}
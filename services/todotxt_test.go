@@ -0,0 +1,70 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func TestTodoTxtRoundTrip(t *testing.T) {
+	due := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	todos := []models.TodoList{
+		{Title: "Call mom +family @phone", Description: "Ask about\nthe weekend", Status: "pending", Priority: "A",
+			DueDate: sql.NullTime{Time: due, Valid: true}, Recurrence: "FREQ=WEEKLY;INTERVAL=2"},
+		{Title: "File taxes", Description: "File taxes", Status: "completed", Priority: "B", AutoComplete: true},
+	}
+
+	line := formatTodoTxtLine(&todos[0])
+	assert.Equal(t, "(A) Call mom +family @phone due:2024-05-01 rrule:FREQ=WEEKLY;INTERVAL=2 desc:Ask%20about%0Athe%20weekend", line)
+	assert.Equal(t, "x File taxes pri:B auto:true", formatTodoTxtLine(&todos[1]))
+
+	data := formatTodoTxtLine(&todos[0]) + "\n\n" + formatTodoTxtLine(&todos[1]) + "\n"
+	records, err := readTodoTxtRecords([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	for i, record := range records {
+		todo, err := parseImportRecord(record)
+		require.NoError(t, err)
+		assert.Equal(t, todos[i].Title, todo.Title)
+		assert.Equal(t, todos[i].Description, todo.Description)
+		assert.Equal(t, todos[i].Status, todo.Status)
+		assert.Equal(t, todos[i].Priority, todo.Priority)
+		assert.Equal(t, todos[i].DueDate.Valid, todo.DueDate.Valid)
+		assert.Equal(t, todos[i].Recurrence, todo.Recurrence)
+		assert.Equal(t, todos[i].AutoComplete, todo.AutoComplete)
+	}
+}
+
+func TestReadTodoTxtRecords(t *testing.T) {
+	records, err := readTodoTxtRecords([]byte("x (C) 2024-05-02 2024-04-01 Water plants @home rec:+3d due:2024-05-05\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"status": "completed", "priority": "C", "title": "Water plants @home", "description": "Water plants @home",
+		"recurrence": "FREQ=DAILY;INTERVAL=3", "due_date": "2024-05-05",
+	}, records[0])
+
+	_, err = readTodoTxtRecords([]byte("Standup rec:5b\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	todo := models.TodoList{Title: "Write report +work", Description: "Section one\nSection two", Status: "completed", Priority: "A"}
+
+	item := formatMarkdownItem(&todo)
+	assert.Equal(t, "- [x] (A) Write report +work\n  Section one\n  Section two\n", item)
+
+	records, err := readMarkdownRecords([]byte("# Tasks\n\n" + item + "\nSome notes\n* [ ] Buy milk due:2024-05-01\n"))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, map[string]string{
+		"status": "completed", "priority": "A", "title": "Write report +work", "description": "Section one\nSection two",
+	}, records[0])
+	assert.Equal(t, map[string]string{
+		"status": "pending", "title": "Buy milk", "description": "Buy milk", "due_date": "2024-05-01",
+	}, records[1])
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// Formats supported by ExportTodos and ImportTodos; ImportTodos also accepts FormatICS
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatTodoTxt  = "todotxt"
+	FormatMarkdown = "markdown"
+)
+
+// MaxImportRows caps the number of todos accepted in a single import
+const MaxImportRows = 5000
+
+var ErrInvalidImport = errors.New("invalid import")
+
+// transferFields lists the fields of an exported todo, in CSV column order; imports accept the same fields
+var transferFields = []string{"id", "title", "description", "status", "priority", "due_date", "parent_id", "auto_complete", "recurrence"}
+
+// TodoFilter narrows down the todos exported or queried; zero values are ignored
+type TodoFilter struct {
+	// OwnerID is the user whose todos are selected; todos of other users never match
+	OwnerID uint
+	Status  string
+	DueFrom time.Time
+	DueTo   time.Time
+	Search  string
+}
+
+// where returns the SQL condition selecting the todos of the owner outside the trash that match the filter,
+// with its bind values
+func (filter TodoFilter) where() (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	addCondition("owner_id = :%d", filter.OwnerID)
+	if filter.Status != "" {
+		addCondition("status = :%d", filter.Status)
+	}
+	if !filter.DueFrom.IsZero() {
+		addCondition("due_date >= :%d", filter.DueFrom)
+	}
+	if !filter.DueTo.IsZero() {
+		addCondition("due_date <= :%d", filter.DueTo)
+	}
+	if filter.Search != "" {
+		addCondition("LOWER(title) LIKE '%%' || LOWER(:%d) || '%%'", filter.Search)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// ImportOptions controls how ImportTodos reads its input. Mapping renames source columns (CSV headers or
+// JSON keys) to todo fields; columns that are neither mapped nor named after a field are ignored.
+type ImportOptions struct {
+	Format  string
+	DryRun  bool
+	Mapping map[string]string
+}
+
+type ImportError struct {
+	// Row is the 1-based position of the record in the input, not counting the CSV header
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Total    int           `json:"total"`
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// IsExportFormat reports whether ExportTodos supports format
+func IsExportFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatJSON, FormatTodoTxt, FormatMarkdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// ExportTodos writes every todo matching filter to w in the given format, streaming rows as they are read
+func ExportTodos(ctx context.Context, w io.Writer, format string, filter TodoFilter) error {
+	if !IsExportFormat(format) {
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	where, args := filter.where()
+	query := `SELECT ` + todoColumns + ` FROM todolist WHERE ` + where + ` ORDER BY id`
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var writeTodo func(todo *models.TodoList) error
+	var finish func() error
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(transferFields); err != nil {
+			return err
+		}
+		writeTodo = func(todo *models.TodoList) error {
+			fields := exportFields(todo)
+			record := make([]string, len(transferFields))
+			for i, name := range transferFields {
+				record[i] = fields[name]
+			}
+			return cw.Write(record)
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		writeTodo = func(todo *models.TodoList) error {
+			data, err := json.Marshal(exportFields(todo))
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err = w.Write(data)
+			return err
+		}
+		finish = func() error {
+			_, err := io.WriteString(w, "]")
+			return err
+		}
+	case FormatTodoTxt:
+		writeTodo = func(todo *models.TodoList) error {
+			_, err := io.WriteString(w, formatTodoTxtLine(todo)+"\n")
+			return err
+		}
+		finish = func() error { return nil }
+	case FormatMarkdown:
+		writeTodo = func(todo *models.TodoList) error {
+			_, err := io.WriteString(w, formatMarkdownItem(todo))
+			return err
+		}
+		finish = func() error { return nil }
+	}
+
+	for rows.Next() {
+		var todo models.TodoList
+		if err := scanTodo(rows, &todo); err != nil {
+			return err
+		}
+		if err := writeTodo(&todo); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return finish()
+}
+
+// exportFields returns the exported fields of a todo as strings, keyed by field name
+func exportFields(todo *models.TodoList) map[string]string {
+	fields := map[string]string{
+		"id":            strconv.Itoa(todo.ID),
+		"title":         todo.Title,
+		"description":   todo.Description,
+		"status":        todo.Status,
+		"priority":      todo.Priority,
+		"due_date":      "",
+		"parent_id":     "",
+		"auto_complete": strconv.FormatBool(todo.AutoComplete),
+		"recurrence":    todo.Recurrence,
+	}
+	if todo.DueDate.Valid {
+		fields["due_date"] = todo.DueDate.Time.Format("2006-01-02")
+	}
+	if todo.ParentID.Valid {
+		fields["parent_id"] = strconv.FormatInt(todo.ParentID.Int64, 10)
+	}
+	return fields
+}
+
+// ImportTodos reads todos from data and, unless the input has errors or opts.DryRun is set, inserts them all
+// in a single transaction. Every record is validated with the TodoInput rules first, so a report listing
+// the errors of every invalid record is returned and nothing is inserted if any of them fails.
+func ImportTodos(ctx context.Context, data []byte, opts ImportOptions) (*ImportReport, error) {
+	for column, field := range opts.Mapping {
+		if !isTransferField(field) {
+			return nil, fmt.Errorf("%w: column %q is mapped to unknown field %q", ErrInvalidImport, column, field)
+		}
+	}
+
+	var records []map[string]string
+	var err error
+	switch opts.Format {
+	case FormatCSV:
+		records, err = readCSVRecords(data)
+	case FormatJSON:
+		records, err = readJSONRecords(data)
+	case FormatICS:
+		records, err = readICSRecords(data)
+	case FormatTodoTxt:
+		records, err = readTodoTxtRecords(data)
+	case FormatMarkdown:
+		records, err = readMarkdownRecords(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(records) == 0 || len(records) > MaxImportRows {
+		return nil, fmt.Errorf("%w: between 1 and %d records are required", ErrInvalidImport, MaxImportRows)
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Total: len(records), Errors: []ImportError{}}
+	todos := make([]*models.TodoList, 0, len(records))
+	for i, record := range records {
+		todo, err := parseImportRecord(applyMapping(record, opts.Mapping))
+		if err != nil {
+			report.Errors = append(report.Errors, ImportError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	if len(report.Errors) > 0 || opts.DryRun {
+		return report, nil
+	}
+
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, 0, len(todos))
+	for i, todo := range todos {
+		if err := createTodoTx(ctx, tx, todo); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		ids = append(ids, strconv.Itoa(todo.ID))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	invalidateTodoCache(ctx, ids...)
+	events.publish(ctx)
+	report.Imported = len(todos)
+	return report, nil
+}
+
+// readCSVRecords reads CSV data whose first row holds the column names
+func readCSVRecords(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		// Spreadsheet exports often start with a byte order mark
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			record[strings.TrimSpace(column)] = row[i]
+		}
+		records = append(records, record)
+		if len(records) > MaxImportRows {
+			return records, nil
+		}
+	}
+}
+
+// readJSONRecords reads a JSON array of objects, converting scalar values to their string form
+func readJSONRecords(data []byte) ([]map[string]string, error) {
+	var objects []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]string, len(objects))
+	for i, object := range objects {
+		record := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				record[key] = ""
+			case string:
+				record[key] = v
+			case json.Number, bool:
+				record[key] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("record %d: field %q must be a string, number or boolean", i+1, key)
+			}
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+func isTransferField(name string) bool {
+	for _, field := range transferFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMapping renames the columns of record according to mapping
+func applyMapping(record, mapping map[string]string) map[string]string {
+	if len(mapping) == 0 {
+		return record
+	}
+
+	mapped := make(map[string]string, len(record))
+	for column, value := range record {
+		if field, ok := mapping[column]; ok {
+			mapped[field] = value
+		} else if _, taken := mapped[column]; !taken {
+			mapped[column] = value
+		}
+	}
+	return mapped
+}
+
+// parseImportRecord builds a todo from an imported record and validates it with the TodoInput rules.
+// The id and parent_id fields are ignored: imported todos are always created as new top-level todos.
+func parseImportRecord(record map[string]string) (*models.TodoList, error) {
+	todo := &models.TodoList{
+		Title:       strings.TrimSpace(record["title"]),
+		Description: strings.TrimSpace(record["description"]),
+		Status:      strings.ToLower(strings.TrimSpace(record["status"])),
+		Recurrence:  strings.TrimSpace(record["recurrence"]),
+		Priority:    strings.ToUpper(strings.TrimSpace(record["priority"])),
+	}
+	if todo.Status == "" {
+		todo.Status = "pending"
+	}
+
+	if due := strings.TrimSpace(record["due_date"]); due != "" {
+		date, err := parseImportDate(due)
+		if err != nil {
+			return nil, fmt.Errorf("invalid due_date %q: expected YYYY-MM-DD", due)
+		}
+		todo.DueDate.Time, todo.DueDate.Valid = date, true
+	}
+
+	if autoComplete := strings.TrimSpace(record["auto_complete"]); autoComplete != "" {
+		value, err := strconv.ParseBool(autoComplete)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auto_complete %q: expected true or false", autoComplete)
+		}
+		todo.AutoComplete = value
+	}
+
+	input := TodoInput{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
+	}
+	if err := validate.Struct(input); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if err := validateRecurrence(todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func parseImportDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
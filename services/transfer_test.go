@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/models"
+)
+
+func TestReadCSVRecords(t *testing.T) {
+	data := "\ufeffTask,Notes,status\nBuy milk,\"two, litres\",pending\n"
+
+	records, err := readCSVRecords([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, map[string]string{"Task": "Buy milk", "Notes": "two, litres", "status": "pending"}, records[0])
+}
+
+func TestReadJSONRecords(t *testing.T) {
+	records, err := readJSONRecords([]byte(`[{"title": "Buy milk", "auto_complete": true, "id": 7, "due_date": null}]`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"title": "Buy milk", "auto_complete": "true", "id": "7", "due_date": ""}, records[0])
+
+	_, err = readJSONRecords([]byte(`[{"title": {"nested": true}}]`))
+	assert.Error(t, err)
+}
+
+func TestParseImportRecord(t *testing.T) {
+	record := applyMapping(map[string]string{"Task": "Buy milk", "Notes": "Semi-skimmed", "due_date": "2024-05-01"},
+		map[string]string{"Task": "title", "Notes": "description"})
+
+	todo, err := parseImportRecord(record)
+	require.NoError(t, err)
+	assert.Equal(t, "Buy milk", todo.Title)
+	assert.Equal(t, "Semi-skimmed", todo.Description)
+	assert.Equal(t, "pending", todo.Status)
+	assert.Equal(t, "2024-05-01", todo.DueDate.Time.Format("2006-01-02"))
+
+	_, err = parseImportRecord(map[string]string{"title": "Buy milk", "description": "x", "due_date": "tomorrow"})
+	assert.ErrorContains(t, err, "invalid due_date")
+
+	_, err = parseImportRecord(map[string]string{"title": "ab", "description": "x"})
+	assert.ErrorContains(t, err, "validation error")
+}
+
+func TestExportTodosOnlyListsTodosOfOwner(t *testing.T) {
+	mock, _ := useMockDB(t)
+	// Todos of other users do not match the condition, so the database returns only those of the owner
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todolist WHERE deleted_at IS NULL AND owner_id = :1 AND status = :2 ORDER BY id")).
+		WithArgs(uint(5), "pending").
+		WillReturnRows(todoRows(models.TodoList{ID: 3, Title: "Pay rent", Description: "flat", Status: "pending",
+			OwnerID: sql.NullInt64{Int64: 5, Valid: true}}))
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportTodos(context.Background(), &buf, FormatJSON, TodoFilter{OwnerID: 5, Status: "pending"}))
+	assert.Contains(t, buf.String(), "Pay rent")
+
+	where, args := TodoFilter{OwnerID: 7}.where()
+	assert.Equal(t, "deleted_at IS NULL AND owner_id = :1", where)
+	assert.Equal(t, []interface{}{uint(7)}, args)
+}
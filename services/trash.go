@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// GetTrashedTodos retrieves a page of todos that have been moved to the trash, most recently deleted first
+func GetTrashedTodos(ctx context.Context, page, limit int) (*PaginatedTodos, error) {
+	startRow := (page - 1) * limit
+	query := `
+        SELECT ` + todoColumns + `
+        FROM (
+            SELECT ` + todoColumns + `, ROW_NUMBER() OVER (ORDER BY deleted_at DESC, id) AS rn
+            FROM todolist WHERE deleted_at IS NOT NULL
+        ) WHERE rn BETWEEN :1 AND :2
+    `
+	rows, err := database.DB.QueryContext(ctx, query, startRow+1, startRow+limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []models.TodoList
+	for rows.Next() {
+		var todo models.TodoList
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var totalTasks int
+	countQuery := `SELECT COUNT(*) FROM todolist WHERE deleted_at IS NOT NULL`
+	if err := database.DB.QueryRowContext(ctx, countQuery).Scan(&totalTasks); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedTodos{
+		Todos:       todos,
+		CurrentPage: page,
+		TotalPages:  (totalTasks + limit - 1) / limit,
+		TotalTasks:  totalTasks,
+	}, nil
+}
+
+// RestoreTodoByID moves a todo item out of the trash by ID, recording the restore in the audit log
+func RestoreTodoByID(ctx context.Context, id string) error {
+	ctx, events := withPendingEvents(ctx)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockTodo(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE todolist SET deleted_at = NULL WHERE id = :1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	after := *before
+	after.DeletedAt = sql.NullTime{}
+	if err := recordAudit(ctx, tx, id, AuditRestore, before, &after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invalidateTodoCache(ctx, id)
+	events.publish(ctx)
+	return nil
+}
+
+// PurgeTodoByID permanently deletes a todo item that is in the trash by ID, recording the purge in the audit log
+func PurgeTodoByID(ctx context.Context, id string) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockTodo(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM todolist WHERE id = :1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(ctx, tx, id, AuditPurge, before, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeExpiredTodos permanently deletes every todo that has been in the trash for longer than retention,
+// recording an actorless purge event for each of them in the audit log
+func PurgeExpiredTodos(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	auditQuery := `INSERT INTO todo_audit (todo_id, operation)
+	               SELECT id, :1 FROM todolist WHERE deleted_at IS NOT NULL AND deleted_at < :2`
+	if _, err := tx.ExecContext(ctx, auditQuery, AuditPurge, cutoff); err != nil {
+		return 0, err
+	}
+
+	query := `DELETE FROM todolist WHERE deleted_at IS NOT NULL AND deleted_at < :1`
+	result, err := tx.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartTrashPurger empties expired items from the trash every interval until ctx is cancelled
+func StartTrashPurger(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeExpiredTodos(ctx, retention)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to purge trash", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "Purged todos from the trash", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"todolist/models"
+)
+
+func TestRestoreTodoByID(t *testing.T) {
+	mock, _ := useMockDB(t)
+	trashed := &models.TodoList{ID: 7, Title: "Buy milk", Description: "groceries", Status: "pending", Version: 2, OwnerID: testOwner,
+		DeletedAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}}
+
+	mock.ExpectBegin()
+	expectLockTodo(mock, true, "7", trashed)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todolist SET deleted_at = NULL WHERE id = :1")).WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, 1)
+	mock.ExpectCommit()
+
+	assert.NoError(t, RestoreTodoByID(ownerContext(), "7"))
+	assert.Equal(t, []string{EventTodoRestored}, publishedEventTypes(t))
+}
+
+func TestRestoreTodoByIDOutsideOfTrash(t *testing.T) {
+	mock, _ := useMockDB(t)
+
+	mock.ExpectBegin()
+	expectLockTodo(mock, true, "7", nil)
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, RestoreTodoByID(ownerContext(), "7"), ErrTodoNotFound)
+	assert.Empty(t, publishedEventTypes(t))
+}
+
+func TestPurgeTodoByID(t *testing.T) {
+	mock, _ := useMockDB(t)
+	trashed := &models.TodoList{ID: 7, Title: "Buy milk", Description: "groceries", Status: "pending", Version: 2, OwnerID: testOwner,
+		DeletedAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}}
+
+	mock.ExpectBegin()
+	expectLockTodo(mock, true, "7", trashed)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todolist WHERE id = :1")).WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 1))
+	// Purging triggers no event, so no webhook delivery is queued
+	expectAudit(mock, 0)
+	mock.ExpectCommit()
+
+	assert.NoError(t, PurgeTodoByID(ownerContext(), "7"))
+}
@@ -1,12 +1,15 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"todolist/database"
 	"todolist/models"
 )
 
-func CreateUser(user *models.User) (map[string]interface{}, error) {
+func CreateUser(ctx context.Context, user *models.User) (map[string]interface{}, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -15,13 +18,13 @@ func CreateUser(user *models.User) (map[string]interface{}, error) {
 
 	// Insert user data into the database
 	query := "INSERT INTO users (username, password) VALUES (:1, :2)"
-	_, err = database.DB.Exec(query, user.Username, user.Password)
+	_, err = database.DB.ExecContext(ctx, query, user.Username, user.Password)
 	if err != nil {
 		return nil, err
 	}
 
 	query = "SELECT id FROM users WHERE username = :1 AND ROWNUM = 1 ORDER BY id DESC"
-	err = database.DB.QueryRow(query, user.Username).Scan(&user.ID)
+	err = database.DB.QueryRowContext(ctx, query, user.Username).Scan(&user.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -33,3 +36,16 @@ func CreateUser(user *models.User) (map[string]interface{}, error) {
 	}
 	return data, nil
 }
+
+// GetUserByID retrieves a user without their password hash
+func GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := database.DB.QueryRowContext(ctx, "SELECT id, username, role FROM users WHERE id = :1", id).
+		Scan(&user.ID, &user.Username, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todolist/database"
+	"todolist/models"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookEventHeader     = "X-Todolist-Event"
+	WebhookDeliveryHeader  = "X-Todolist-Delivery"
+	WebhookSignatureHeader = "X-Todolist-Signature"
+)
+
+const (
+	// maxWebhookAttempts is the number of failed deliveries after which a delivery is marked as failed
+	maxWebhookAttempts = 8
+	// webhookLease is how long a dispatcher instance owns a claimed delivery
+	webhookLease = 2 * time.Minute
+	// webhookBatchSize caps the number of deliveries claimed per dispatcher tick
+	webhookBatchSize = 50
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type WebhookInput struct {
+	URL    string   `validate:"required,http_url,max=500" json:"url"`
+	Events []string `validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted todo.restored" json:"events"`
+	// Secret is generated when omitted
+	Secret string `validate:"omitempty,min=16,max=128" json:"secret"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	Event      string                        `json:"event"`
+	OccurredAt time.Time                     `json:"occurred_at"`
+	ActorID    *uint                         `json:"actor_id"`
+	Todo       *models.TodoList              `json:"todo"`
+	Changes    map[string]models.FieldChange `json:"changes"`
+}
+
+type PaginatedWebhookDeliveries struct {
+	Deliveries      []models.WebhookDelivery `json:"deliveries"`
+	CurrentPage     int                      `json:"current_page"`
+	TotalPages      int                      `json:"total_pages"`
+	TotalDeliveries int                      `json:"total_deliveries"`
+}
+
+// SignWebhookPayload returns the value of the signature header for body: "sha256=" followed by
+// the hex-encoded HMAC-SHA256 of body keyed with the webhook secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature is a valid signature of body for secret
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, body)), []byte(signature))
+}
+
+const webhookColumns = `id, user_id, url, secret, events, active, created_at`
+
+func scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	var events string
+	var active int
+	err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &events, &active, &webhook.CreatedAt)
+	webhook.Events = strings.Split(events, ",")
+	webhook.Active = active == 1
+	return err
+}
+
+// ListWebhooks retrieves the webhooks registered by a user; secrets are not included
+func ListWebhooks(ctx context.Context, userID uint) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = :1 ORDER BY id`
+	rows, err := database.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, err
+		}
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// CreateWebhook validates and registers a webhook for a user. The returned webhook is the only place
+// its secret is ever shown.
+func CreateWebhook(ctx context.Context, userID uint, input WebhookInput) (*models.Webhook, error) {
+	if err := validate.Struct(input); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if err := CheckDestination(ctx, input.URL); err != nil {
+		return nil, err
+	}
+
+	if input.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		input.Secret = hex.EncodeToString(secret)
+	}
+
+	webhook := models.Webhook{
+		UserID:    userID,
+		URL:       input.URL,
+		Secret:    input.Secret,
+		Events:    input.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	query := `INSERT INTO webhooks (user_id, url, secret, events) VALUES (:1, :2, :3, :4) RETURNING id INTO :5`
+	_, err := database.DB.ExecContext(ctx, query, userID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","),
+		sql.Out{Dest: &webhook.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook registered by a user along with its delivery log
+func DeleteWebhook(ctx context.Context, userID uint, id string) error {
+	result, err := database.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = :1 AND user_id = :2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// GetWebhookDeliveries retrieves a page of the delivery log of a webhook registered by a user, newest first
+func GetWebhookDeliveries(ctx context.Context, userID uint, webhookID string, page, limit int) (*PaginatedWebhookDeliveries, error) {
+	var owned int
+	if err := database.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM webhooks WHERE id = :1 AND user_id = :2`, webhookID, userID).Scan(&owned); err != nil {
+		return nil, err
+	}
+	if owned == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	startRow := (page - 1) * limit
+	query := `
+        SELECT id, webhook_id, event, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+        FROM (
+            SELECT d.*, ROW_NUMBER() OVER (ORDER BY created_at DESC, id DESC) AS rn
+            FROM webhook_deliveries d WHERE webhook_id = :1
+        ) WHERE rn BETWEEN :2 AND :3
+    `
+	rows, err := database.DB.QueryContext(ctx, query, webhookID, startRow+1, startRow+limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode,
+			&d.LastError, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var totalDeliveries int
+	countQuery := `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = :1`
+	if err := database.DB.QueryRowContext(ctx, countQuery, webhookID).Scan(&totalDeliveries); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedWebhookDeliveries{
+		Deliveries:      deliveries,
+		CurrentPage:     page,
+		TotalPages:      (totalDeliveries + limit - 1) / limit,
+		TotalDeliveries: totalDeliveries,
+	}, nil
+}
+
+// RedeliverWebhook queues a new delivery with the same event and payload as an earlier one
+// and returns the ID of the new delivery
+func RedeliverWebhook(ctx context.Context, userID uint, webhookID, deliveryID string) (int, error) {
+	var event, payload string
+	query := `SELECT d.event, d.payload FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	          WHERE d.id = :1 AND d.webhook_id = :2 AND w.user_id = :3`
+	err := database.DB.QueryRowContext(ctx, query, deliveryID, webhookID, userID).Scan(&event, &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDeliveryNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	var id int
+	query = `INSERT INTO webhook_deliveries (webhook_id, event, payload) VALUES (:1, :2, :3) RETURNING id INTO :4`
+	if _, err := database.DB.ExecContext(ctx, query, webhookID, event, payload, sql.Out{Dest: &id}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// enqueueWebhookEvents queues a delivery within tx for every active webhook of the todo's owner subscribed to
+// the events triggered by a todo change, so that deliveries exist if and only if the change is committed
+func enqueueWebhookEvents(ctx context.Context, tx *sql.Tx, operation string, before, after *models.TodoList, changes map[string]models.FieldChange) error {
+	events := todoEventsFor(operation, before, after)
+	if len(events) == 0 {
+		return nil
+	}
+
+	payload := WebhookPayload{OccurredAt: time.Now().UTC(), Todo: after, Changes: changes}
+	if after == nil {
+		payload.Todo = before
+	}
+	if !payload.Todo.OwnerID.Valid {
+		// Todos created before owners were recorded are not delivered to anyone
+		return nil
+	}
+	if userID, ok := ActorFromContext(ctx); ok {
+		payload.ActorID = &userID
+	}
+
+	for _, event := range events {
+		payload.Event = event
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		          SELECT id, :1, :2 FROM webhooks
+		          WHERE user_id = :3 AND active = 1 AND INSTR(',' || events || ',', ',' || :4 || ',') > 0`
+		if _, err := tx.ExecContext(ctx, query, event, string(body), payload.Todo.OwnerID.Int64, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartWebhookDispatcher delivers queued webhook deliveries every interval until ctx is cancelled.
+// Several instances may run concurrently: each delivery is claimed with a lease before it is sent.
+func StartWebhookDispatcher(ctx context.Context, client *http.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := dispatchWebhooks(ctx, client); err != nil {
+			slog.ErrorContext(ctx, "Failed to dispatch webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimedDelivery is a delivery leased by the dispatcher together with its destination
+type claimedDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+func dispatchWebhooks(ctx context.Context, client *http.Client) error {
+	claimed, err := claimWebhookDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range claimed {
+		statusCode, err := sendWebhook(ctx, client, delivery.URL, delivery.Secret, delivery.WebhookDelivery)
+		recordWebhookAttempt(ctx, delivery.WebhookDelivery, statusCode, err)
+	}
+	return nil
+}
+
+func claimWebhookDeliveries(ctx context.Context) ([]claimedDelivery, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+        FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+        WHERE d.status = 'pending' AND w.active = 1
+          AND (d.next_attempt_at IS NULL OR d.next_attempt_at <= SYSTIMESTAMP)
+          AND (d.locked_until IS NULL OR d.locked_until < SYSTIMESTAMP)
+        ORDER BY d.id
+        FOR UPDATE OF d.locked_until SKIP LOCKED
+    `
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []claimedDelivery
+	for rows.Next() && len(deliveries) < webhookBatchSize {
+		var d claimedDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	leaseUntil := time.Now().Add(webhookLease)
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET locked_until = :1 WHERE id = :2`, leaseUntil, d.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// sendWebhook posts the payload of delivery to url, signed with secret, and returns the response status code.
+// Any non-2xx response is reported as an error.
+func sendWebhook(ctx context.Context, client *http.Client, url, secret string, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordWebhookAttempt stores the outcome of a delivery attempt, scheduling a retry with exponential backoff on failure
+func recordWebhookAttempt(ctx context.Context, delivery models.WebhookDelivery, statusCode int, cause error) {
+	attempts := delivery.Attempts + 1
+	lastStatusCode := sql.NullInt64{Int64: int64(statusCode), Valid: statusCode != 0}
+
+	var query string
+	var args []interface{}
+	if cause == nil {
+		query = `UPDATE webhook_deliveries SET status = :1, attempts = :2, last_status_code = :3, last_error = NULL,
+		         delivered_at = SYSTIMESTAMP, locked_until = NULL WHERE id = :4`
+		args = []interface{}{DeliverySucceeded, attempts, lastStatusCode, delivery.ID}
+	} else {
+		status := DeliveryPending
+		if attempts >= maxWebhookAttempts {
+			status = DeliveryFailed
+		}
+		query = `UPDATE webhook_deliveries SET status = :1, attempts = :2, last_status_code = :3, last_error = :4,
+		         next_attempt_at = :5, locked_until = NULL WHERE id = :6`
+		args = []interface{}{status, attempts, lastStatusCode, truncate(cause.Error(), 1000), time.Now().Add(retryBackoff(attempts)), delivery.ID}
+	}
+
+	if _, err := database.DB.ExecContext(ctx, query, args...); err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
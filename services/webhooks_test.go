@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"todolist/database"
+	"todolist/models"
+)
+
+func TestSendWebhook(t *testing.T) {
+	const secret = "0123456789abcdef"
+	delivery := models.WebhookDelivery{ID: 42, Event: EventTodoCompleted, Payload: `{"event":"todo.completed"}`}
+
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	statusCode, err := sendWebhook(context.Background(), receiver.Client(), receiver.URL, secret, delivery)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, statusCode)
+
+	assert.Equal(t, delivery.Payload, string(body))
+	assert.Equal(t, EventTodoCompleted, received.Header.Get(WebhookEventHeader))
+	assert.Equal(t, "42", received.Header.Get(WebhookDeliveryHeader))
+	assert.True(t, VerifyWebhookSignature(secret, body, received.Header.Get(WebhookSignatureHeader)))
+	assert.False(t, VerifyWebhookSignature("another secret!!", body, received.Header.Get(WebhookSignatureHeader)))
+}
+
+func TestSendWebhookFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	statusCode, err := sendWebhook(context.Background(), receiver.Client(), receiver.URL, "secret", models.WebhookDelivery{ID: 1})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+}
+
+func TestTodoEventsFor(t *testing.T) {
+	pending := &models.TodoList{Status: "pending"}
+	completed := &models.TodoList{Status: "completed"}
+
+	assert.Equal(t, []string{EventTodoCreated}, todoEventsFor(AuditCreate, nil, pending))
+	assert.Equal(t, []string{EventTodoUpdated, EventTodoCompleted}, todoEventsFor(AuditUpdate, pending, completed))
+	assert.Equal(t, []string{EventTodoUpdated}, todoEventsFor(AuditUpdate, completed, completed))
+	assert.Equal(t, []string{EventTodoDeleted}, todoEventsFor(AuditDelete, pending, nil))
+	assert.Equal(t, []string{EventTodoRestored}, todoEventsFor(AuditRestore, pending, pending))
+	assert.Empty(t, todoEventsFor(AuditPurge, pending, nil))
+}
+
+func TestEnqueueWebhookEventsOnlyReachesOwner(t *testing.T) {
+	mock, _ := useMockDB(t)
+	owned := &models.TodoList{ID: 7, Title: "Buy milk", Status: "pending", OwnerID: sql.NullInt64{Int64: 5, Valid: true}}
+	unowned := &models.TodoList{ID: 8, Title: "Buy bread", Status: "pending"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_deliveries")).
+		WithArgs(EventTodoCreated, sqlmock.AnyArg(), int64(5), EventTodoCreated).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	tx, err := database.DB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, enqueueWebhookEvents(ownerContext(), tx, AuditCreate, nil, owned, nil))
+	// Nobody is notified about todos without an owner
+	require.NoError(t, enqueueWebhookEvents(ownerContext(), tx, AuditCreate, nil, unowned, nil))
+}
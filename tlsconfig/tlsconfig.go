@@ -0,0 +1,236 @@
+// Package tlsconfig builds the TLS configuration of the servers from certificate files, reloading them
+// when they change so certificates can be renewed without a restart
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"todolist/helper"
+)
+
+// Client certificate policies selected with TLS_CLIENT_AUTH. Client certificates only restrict who may
+// connect: they do not identify a user, so calls still authenticate with a bearer token.
+const (
+	// ClientAuthVerifyIfGiven verifies the certificates clients present, without requiring one
+	ClientAuthVerifyIfGiven = "verify_if_given"
+	// ClientAuthRequire refuses clients without a certificate signed by the client CA
+	ClientAuthRequire = "require"
+)
+
+// CertReloader serves a certificate and key pair read from files, along with the CAs client certificates
+// are verified against when there are any, reloading them once they change
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu              sync.RWMutex
+	cert            *tls.Certificate
+	modTime         time.Time
+	clientCAs       *x509.CertPool
+	clientCAModTime time.Time
+}
+
+// NewCertReloader loads the certificate in certFile and its key in keyFile
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the certificate and client CAs again if their files changed since they were last loaded,
+// and reports whether any did. The current ones are kept when the files cannot be loaded, such as while
+// they are written.
+func (r *CertReloader) Reload() (bool, error) {
+	reloaded, err := r.reloadCertificate()
+	if err != nil || r.clientCAFile == "" {
+		return reloaded, err
+	}
+	reloadedCAs, err := r.reloadClientCAs()
+	return reloaded || reloadedCAs, err
+}
+
+func (r *CertReloader) reloadCertificate() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && !modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *CertReloader) reloadClientCAs() (bool, error) {
+	modTime, err := latestModTime(r.clientCAFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.clientCAs != nil && !modTime.After(r.clientCAModTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	pem, err := os.ReadFile(r.clientCAFile)
+	if err != nil {
+		return false, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return false, errors.New("no certificate found in " + r.clientCAFile)
+	}
+
+	r.mu.Lock()
+	r.clientCAs, r.clientCAModTime = pool, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// verifyClientCertificate checks the certificate a client presented, if any, against the current client
+// CAs, as crypto/tls would against tls.Config.ClientCAs
+func (r *CertReloader) verifyClientCertificate(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+	roots := r.clientCAs
+	r.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// Watch checks the files for changes every interval until ctx is cancelled
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to reload TLS certificate", "cert_file", r.certFile,
+				"client_ca_file", r.clientCAFile, "error", err)
+		} else if reloaded {
+			slog.InfoContext(ctx, "Reloaded TLS certificate", "cert_file", r.certFile, "client_ca_file", r.clientCAFile)
+		}
+	}
+}
+
+// latestModTime returns the time the most recently changed of files was modified. Stat follows symbolic
+// links, so certificates mounted from a Kubernetes secret are seen changing when the link is swapped.
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// New creates a server configuration serving the certificate of reloader. When clientCAFile is set, client
+// certificates are verified against the CAs it holds, following clientAuth; reloader then reloads the CAs
+// along with the certificate. A verified client certificate is not mapped to a user: mutual TLS only
+// secures the transport, and the APIs keep authenticating calls by their bearer token.
+func New(reloader *CertReloader, clientCAFile, clientAuth string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	// crypto/tls would only verify against a fixed tls.Config.ClientCAs, so the certificate is requested
+	// without verification and verified against the current CAs of reloader instead
+	switch clientAuth {
+	case "", ClientAuthVerifyIfGiven:
+		config.ClientAuth = tls.RequestClientCert
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAnyClientCert
+	default:
+		return nil, fmt.Errorf("unknown TLS_CLIENT_AUTH %q", clientAuth)
+	}
+
+	reloader.clientCAFile = clientCAFile
+	if _, err := reloader.reloadClientCAs(); err != nil {
+		return nil, err
+	}
+	config.VerifyConnection = reloader.verifyClientCertificate
+	return config, nil
+}
+
+// FromEnv creates the server configuration for the certificate in TLS_CERT_FILE and the key in TLS_KEY_FILE,
+// verifying client certificates against TLS_CLIENT_CA_FILE when it is set, following TLS_CLIENT_AUTH. It
+// returns nil when no certificate is configured, in which case the servers use plain connections.
+func FromEnv() (*tls.Config, *CertReloader, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		return nil, nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := New(reloader, os.Getenv("TLS_CLIENT_CA_FILE"), helper.GetEnv("TLS_CLIENT_AUTH", ClientAuthVerifyIfGiven))
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, reloader, nil
+}
@@ -0,0 +1,199 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate for name signed by parent, or a self-signed CA when parent is nil
+func issue(t *testing.T, name string, parent *testCert, usage ...x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  usage,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+// write stores the certificate and key as PEM files in dir, returning their paths
+func (c *testCert) write(t *testing.T, dir string) (string, string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestCertReloaderPicksUpRenewedCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	first := issue(t, "localhost", ca, x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := first.write(t, dir)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	current, _ := reloader.GetCertificate(nil)
+	assert.Equal(t, first.cert.Raw, current.Certificate[0])
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	renewed := issue(t, "localhost", ca, x509.ExtKeyUsageServerAuth)
+	renewed.write(t, dir)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	current, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, renewed.cert.Raw, current.Certificate[0])
+
+	// A half-written certificate is not loaded, and the previous one keeps being served
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute)))
+	_, err = reloader.Reload()
+	assert.Error(t, err)
+	current, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, renewed.cert.Raw, current.Certificate[0])
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	server := issue(t, "localhost", ca, x509.ExtKeyUsageServerAuth)
+	client := issue(t, "worker", ca, x509.ExtKeyUsageClientAuth)
+	stranger := issue(t, "stranger", issue(t, "other-ca", nil), x509.ExtKeyUsageClientAuth)
+
+	certFile, keyFile := server.write(t, dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	config, err := New(reloader, caFile, ClientAuthRequire)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if conn.(*tls.Conn).Handshake() == nil {
+					conn.Write([]byte("hello"))
+				}
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	dial := func(cert *testCert) error {
+		clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if cert != nil {
+			clientConfig.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		// TLS 1.3 reports a rejected client certificate on the first read
+		_, err = io.ReadAll(conn)
+		return err
+	}
+
+	assert.NoError(t, dial(client))
+	assert.Error(t, dial(nil))
+	assert.Error(t, dial(stranger))
+
+	// A rotated client CA takes effect on reload, without a restart
+	otherCA := issue(t, "other-ca", nil)
+	newcomer := issue(t, "newcomer", otherCA, x509.ExtKeyUsageClientAuth)
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCA.cert.Raw}), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(caFile, later, later))
+	assert.Error(t, dial(newcomer))
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.NoError(t, dial(newcomer))
+	assert.Error(t, dial(client))
+}
+
+func TestFromEnv(t *testing.T) {
+	config, reloader, err := FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, config)
+	assert.Nil(t, reloader)
+
+	t.Setenv("TLS_CERT_FILE", "tls.crt")
+	_, _, err = FromEnv()
+	assert.Error(t, err)
+}
+
+func TestNewRejectsInvalidClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "ca", nil)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+
+	config, err := New(&CertReloader{}, caFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequestClientCert, config.ClientAuth)
+
+	_, err = New(&CertReloader{}, caFile, "sometimes")
+	assert.Error(t, err)
+	_, err = New(&CertReloader{}, filepath.Join(dir, "missing.crt"), "")
+	assert.Error(t, err)
+}
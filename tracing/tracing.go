@@ -0,0 +1,159 @@
+// Package tracing sets up OpenTelemetry tracing and creates a span for every HTTP request
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"todolist/helper"
+	"todolist/logging"
+)
+
+// Exporters selected with OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+const tracerName = "todolist"
+
+// Init installs the global tracer provider and the W3C trace context propagator. Spans are sent to the
+// exporter named by OTEL_TRACES_EXPORTER: "otlp" sends them over gRPC to OTEL_EXPORTER_OTLP_ENDPOINT
+// (localhost:4317 by default), "stdout" prints them and "none" drops them. Without OTEL_TRACES_EXPORTER,
+// spans are only exported when an OTLP endpoint is configured. The returned function flushes pending spans.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" {
+		exporter = ExporterNone
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporter = ExporterOTLP
+		}
+	}
+
+	var opts []sdktrace.TracerProviderOption
+	switch exporter {
+	case ExporterOTLP:
+		spanExporter, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(spanExporter))
+	case ExporterStdout:
+		spanExporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(spanExporter))
+	case ExporterNone:
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporter)
+	}
+
+	provider := NewProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider identifying this service, named by OTEL_SERVICE_NAME
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(semconv.ServiceName(helper.GetEnv("OTEL_SERVICE_NAME", "todolist")))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
+
+// NewStdoutProvider creates a tracer provider printing every span to w as soon as it ends, for tests
+func NewStdoutProvider(w io.Writer) (*sdktrace.TracerProvider, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(sdktrace.WithSyncer(exporter)), nil
+}
+
+// TraceID returns the ID of the trace ctx belongs to, or an empty string outside of a trace
+func TraceID(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
+
+// Middleware starts a server span for every request, continuing the trace of the caller when it sends a
+// traceparent header. The span is stored in the user context of the request, which handlers pass on to the
+// services so database and Redis spans become its children.
+func Middleware() fiber.Handler {
+	tracer := otel.Tracer(tracerName)
+
+	return func(c *fiber.Ctx) error {
+		// Spans are exported to the collector, so the path is recorded without the credentials it may carry
+		path := logging.RedactPath(c.Path())
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		ctx, span := tracer.Start(ctx, c.Method()+" "+path, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(path),
+				semconv.URLScheme(c.Protocol()),
+				semconv.UserAgentOriginal(c.Get(fiber.HeaderUserAgent)),
+			))
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+
+		// Name the span after the matched route rather than the path, so IDs do not make every name unique
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			span.RecordError(err)
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+		span.SetAttributes(attribute.Int(string(semconv.HTTPResponseStatusCodeKey), status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		return err
+	}
+}
+
+// headerCarrier reads and writes trace context propagation headers on a Fiber request
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
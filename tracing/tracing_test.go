@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"todolist/helper"
+	"todolist/logging"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent   = "00-" + parentTraceID + "-00f067aa0ba902b7-01"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	var spans bytes.Buffer
+	provider, err := NewStdoutProvider(&spans)
+	require.NoError(t, err)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	var handlerTraceID string
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/todo/:id", func(c *fiber.Ctx) error {
+		handlerTraceID = TraceID(c.UserContext())
+		helper.RespondJSON(c, fiber.StatusNotFound, "Todo not found", nil, "no rows")
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/todo/42", nil)
+	req.Header.Set("traceparent", traceparent)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, parentTraceID, handlerTraceID)
+
+	var body helper.ResponseData
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, parentTraceID, body.TraceID)
+
+	var span struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Parent      struct{ SpanID string }
+		Attributes  []struct {
+			Key   string
+			Value struct{ Value interface{} }
+		}
+	}
+	require.NoError(t, json.Unmarshal(spans.Bytes(), &span))
+	assert.Equal(t, "GET /todo/:id", span.Name)
+	assert.Equal(t, parentTraceID, span.SpanContext.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID)
+
+	attributes := map[string]interface{}{}
+	for _, attribute := range span.Attributes {
+		attributes[attribute.Key] = attribute.Value.Value
+	}
+	assert.Equal(t, "/todo/:id", attributes["http.route"])
+	assert.EqualValues(t, fiber.StatusNotFound, attributes["http.response.status_code"])
+}
+
+func TestMiddlewareRedactsCalendarToken(t *testing.T) {
+	var spans bytes.Buffer
+	provider, err := NewStdoutProvider(&spans)
+	require.NoError(t, err)
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/calendar/:token/todos.ics", func(c *fiber.Ctx) error { return c.SendString("BEGIN:VCALENDAR") })
+
+	_, err = app.Test(httptest.NewRequest("GET", "/calendar/secret-token/todos.ics", nil))
+	require.NoError(t, err)
+	assert.NotContains(t, spans.String(), "secret-token")
+	assert.Contains(t, spans.String(), "/calendar/"+logging.Redacted+"/todos.ics")
+}
+
+func TestTraceIDOutsideOfTrace(t *testing.T) {
+	assert.Empty(t, TraceID(context.Background()))
+}